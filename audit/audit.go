@@ -0,0 +1,199 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/jagoe/haste-client-go/util"
+)
+
+// #region Setup
+
+const (
+	lockTimeout = 5 * time.Second
+	// tailBlockSize is the size of the blocks that are read from the end of the log to find the last entry
+	tailBlockSize = 4096
+)
+
+// Entry records a haste that has been created or retrieved
+// Every entry contains the hash of the previous one, so changing or removing entries breaks the chain.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	Host      string    `json:"host"`
+	Action    string    `json:"action"`
+	Server    string    `json:"server"`
+	Key       string    `json:"key"`
+	SHA256    string    `json:"sha256"`
+	Size      int64     `json:"size"`
+	Source    string    `json:"source,omitempty"`
+	Decisions []string  `json:"decisions,omitempty"`
+	Previous  string    `json:"previous"`
+	Hash      string    `json:"hash"`
+}
+
+// Log is an append-only, hash-chained log of JSON lines
+type Log struct {
+	Path string
+}
+
+// ChainError is returned if an entry of the log has been modified, removed or inserted
+type ChainError struct {
+	Line   int
+	Reason string
+}
+
+func (err *ChainError) Error() string {
+	return fmt.Sprintf("Audit log has been tampered with: line %d: %s", err.Line, err.Reason)
+}
+
+// CurrentUser returns the name of the user and host the client is running as
+func CurrentUser() (string, string) {
+	name := os.Getenv("USER")
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+
+	host, _ := os.Hostname()
+
+	return name, host
+}
+
+// #endregion
+
+// Append chains an entry to the last entry of the log and appends it
+func (log Log) Append(entry Entry) error {
+	if err := os.MkdirAll(filepath.Dir(log.Path), 0700); err != nil {
+		return fmt.Errorf("Error writing audit log: %s", err.Error())
+	}
+
+	unlock, err := util.LockFile(log.Path, lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	file, err := os.OpenFile(log.Path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("Error writing audit log: %s", err.Error())
+	}
+	defer file.Close()
+
+	last, err := lastLine(file)
+	if err != nil {
+		return fmt.Errorf("Error reading audit log: %s", err.Error())
+	}
+
+	entry.Previous = ""
+	if len(last) > 0 {
+		var previous Entry
+		if err := json.Unmarshal(last, &previous); err != nil {
+			return fmt.Errorf("Error reading audit log: %s", err.Error())
+		}
+		entry.Previous = previous.Hash
+	}
+
+	entry.Hash = hashEntry(entry)
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("Error writing audit log: %s", err.Error())
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("Error writing audit log: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Verify checks the hash chain of the log and returns the number of valid entries
+// A ChainError points to the first entry that breaks the chain.
+func (log Log) Verify() (int, error) {
+	file, err := os.Open(log.Path)
+	if err != nil {
+		return 0, fmt.Errorf("Error reading audit log: %s", err.Error())
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	previous := ""
+	count := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return count, fmt.Errorf("Error reading audit log: %s", err.Error())
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			var entry Entry
+			if jsonErr := json.Unmarshal(line, &entry); jsonErr != nil {
+				return count, &ChainError{Line: count + 1, Reason: "not a valid entry"}
+			}
+			if entry.Previous != previous {
+				return count, &ChainError{Line: count + 1, Reason: "does not follow the previous entry"}
+			}
+			if hashEntry(entry) != entry.Hash {
+				return count, &ChainError{Line: count + 1, Reason: "has been modified"}
+			}
+
+			previous = entry.Hash
+			count++
+		}
+
+		if err == io.EOF {
+			return count, nil
+		}
+	}
+}
+
+// #region Private
+
+// hashEntry hashes the JSON representation of an entry without its own hash
+func hashEntry(entry Entry) string {
+	entry.Hash = ""
+	content, _ := json.Marshal(entry)
+	sum := sha256.Sum256(content)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// lastLine reads the last non-empty line of a file from its end, so the log does not have to be read completely
+func lastLine(file *os.File) ([]byte, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var tail []byte
+	for offset := info.Size(); offset > 0; {
+		size := int64(tailBlockSize)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+
+		block := make([]byte, size)
+		if _, err := file.ReadAt(block, offset); err != nil {
+			return nil, err
+		}
+		tail = append(block, tail...)
+
+		trimmed := bytes.TrimRight(tail, "\n")
+		if index := bytes.LastIndexByte(trimmed, '\n'); index >= 0 {
+			return trimmed[index+1:], nil
+		}
+	}
+
+	return bytes.TrimRight(tail, "\n"), nil
+}
+
+// #endregion
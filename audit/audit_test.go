@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// #region Setup
+
+func tempLog(t *testing.T) Log {
+	dir, _ := ioutil.TempDir("", "haste-audit")
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return Log{Path: filepath.Join(dir, "audit.jsonl")}
+}
+
+func appendEntries(t *testing.T, log Log, count int) {
+	for i := 0; i < count; i++ {
+		err := log.Append(Entry{Time: time.Now().UTC(), Action: "create", Key: strings.Repeat("k", i+1), Size: int64(i)})
+		if err != nil {
+			t.Fatalf("Expected Append not to return an error, got %s", err.Error())
+		}
+	}
+}
+
+// #endregion
+
+func TestAppend(t *testing.T) {
+	t.Run("should chain entries", func(t *testing.T) {
+		log := tempLog(t)
+		appendEntries(t, log, 3)
+
+		count, err := log.Verify()
+		if err != nil || count != 3 {
+			t.Errorf("Expected 3 valid entries, got %d, %v", count, err)
+		}
+	})
+
+	t.Run("should find the last entry of long logs", func(t *testing.T) {
+		log := tempLog(t)
+		log.Append(Entry{Source: strings.Repeat("s", 3*tailBlockSize)})
+		appendEntries(t, log, 2)
+
+		if _, err := log.Verify(); err != nil {
+			t.Errorf("Expected the chain to be valid, got %s", err.Error())
+		}
+	})
+}
+
+func TestVerify(t *testing.T) {
+	t.Run("should detect modified entries", func(t *testing.T) {
+		log := tempLog(t)
+		appendEntries(t, log, 3)
+
+		content, _ := ioutil.ReadFile(log.Path)
+		ioutil.WriteFile(log.Path, bytes.Replace(content, []byte(`"size":1`), []byte(`"size":9`), 1), 0600)
+
+		count, err := log.Verify()
+		chainErr, ok := err.(*ChainError)
+		if !ok || chainErr.Line != 2 || count != 1 {
+			t.Errorf("Expected line 2 to be reported as modified, got %d, %v", count, err)
+		}
+	})
+
+	t.Run("should detect removed entries", func(t *testing.T) {
+		log := tempLog(t)
+		appendEntries(t, log, 3)
+
+		content, _ := ioutil.ReadFile(log.Path)
+		lines := strings.SplitAfter(string(content), "\n")
+		ioutil.WriteFile(log.Path, []byte(lines[0]+lines[2]), 0600)
+
+		if _, err := log.Verify(); err == nil {
+			t.Error("Expected Verify to return an error")
+		}
+	})
+}
@@ -0,0 +1,349 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jagoe/haste-client-go/server"
+	"github.com/jagoe/haste-client-go/util"
+)
+
+// #region Setup
+
+// DefaultMaxSize is the default size cap of the cache in bytes
+const DefaultMaxSize = 100 * 1024 * 1024
+
+const (
+	lockTimeout = 5 * time.Second
+	orphanAge   = time.Minute
+)
+
+// Item is a cached haste; its content is stored as a blob named by its SHA-256 hash
+type Item struct {
+	Server   string    `json:"server"`
+	Key      string    `json:"key"`
+	SHA256   string    `json:"sha256"`
+	Size     int64     `json:"size"`
+	Fetched  time.Time `json:"fetched"`
+	Accessed time.Time `json:"accessed"`
+
+	server.Validators
+}
+
+// Cache is an on-disk, content-addressed cache for hastes with a size cap and least-recently-used eviction
+type Cache struct {
+	Dir     string
+	MaxSize int64
+}
+
+// Getter serves hastes from the cache and revalidates them with the server
+// In offline mode, hastes are only served from the cache.
+type Getter struct {
+	Getter  server.ConditionalHasteGetter
+	Server  string
+	Cache   Cache
+	Offline bool
+}
+
+// DefaultDir returns the location of the cache in the XDG cache directory
+func DefaultDir() (string, error) {
+	return util.CacheDir()
+}
+
+// #endregion
+
+// Get returns a haste from the cache if it is still valid or otherwise retrieves and caches it
+func (getter Getter) Get(key string, client *http.Client) (string, error) {
+	item, content, cached := getter.Cache.Lookup(getter.Server, key)
+
+	if getter.Offline {
+		if !cached {
+			return "", fmt.Errorf("Haste %s is not cached and cannot be retrieved offline", key)
+		}
+
+		getter.Cache.Touch(*item)
+		return string(content), nil
+	}
+
+	var validators server.Validators
+	if cached {
+		validators = item.Validators
+	}
+
+	haste, validators, notModified, err := getter.Getter.GetConditional(key, validators, client)
+	if err != nil {
+		return "", err
+	}
+
+	if notModified {
+		getter.Cache.Touch(*item)
+		return string(content), nil
+	}
+
+	// caching is best effort - the haste has been retrieved either way
+	getter.Cache.Store(getter.Server, key, []byte(haste), validators)
+	return haste, nil
+}
+
+// Lookup returns a cached haste and its content
+func (cache Cache) Lookup(serverURL string, key string) (*Item, []byte, bool) {
+	index, err := cache.readIndex()
+	if err != nil {
+		return nil, nil, false
+	}
+
+	item, ok := index[itemID(serverURL, key)]
+	if !ok {
+		return nil, nil, false
+	}
+
+	content, err := ioutil.ReadFile(cache.blobPath(item.SHA256))
+	if err != nil || hashBytes(content) != item.SHA256 {
+		return nil, nil, false
+	}
+
+	return &item, content, true
+}
+
+// Store adds a haste to the cache and evicts the least recently used hastes if the cache exceeds its size cap
+func (cache Cache) Store(serverURL string, key string, content []byte, validators server.Validators) error {
+	hash := hashBytes(content)
+	if err := os.MkdirAll(filepath.Join(cache.Dir, "blobs"), 0700); err != nil {
+		return fmt.Errorf("Error writing cache: %s", err.Error())
+	}
+
+	if _, err := os.Stat(cache.blobPath(hash)); os.IsNotExist(err) {
+		temp := cache.blobPath(hash) + ".tmp"
+		if err := ioutil.WriteFile(temp, content, 0600); err != nil {
+			return fmt.Errorf("Error writing cache: %s", err.Error())
+		}
+		if err := os.Rename(temp, cache.blobPath(hash)); err != nil {
+			os.Remove(temp)
+			return fmt.Errorf("Error writing cache: %s", err.Error())
+		}
+	}
+
+	now := time.Now().UTC()
+	return cache.updateIndex(func(index map[string]Item) {
+		index[itemID(serverURL, key)] = Item{
+			Server:     serverURL,
+			Key:        key,
+			SHA256:     hash,
+			Size:       int64(len(content)),
+			Fetched:    now,
+			Accessed:   now,
+			Validators: validators,
+		}
+		cache.evict(index, cache.MaxSize)
+	})
+}
+
+// Touch marks a cached haste as recently used
+func (cache Cache) Touch(item Item) error {
+	return cache.updateIndex(func(index map[string]Item) {
+		id := itemID(item.Server, item.Key)
+		if current, ok := index[id]; ok {
+			current.Accessed = time.Now().UTC()
+			index[id] = current
+		}
+	})
+}
+
+// List returns all cached hastes, most recently used first
+func (cache Cache) List() ([]Item, error) {
+	index, err := cache.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(index))
+	for _, item := range index {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Accessed.After(items[j].Accessed) })
+
+	return items, nil
+}
+
+// Prune removes hastes that have not been used since olderThan, evicts hastes until the cache fits into maxSize and
+// deletes blobs that are no longer referenced
+// It returns the number of removed hastes.
+func (cache Cache) Prune(olderThan time.Time, maxSize int64) (int, error) {
+	removed := 0
+	err := cache.updateIndex(func(index map[string]Item) {
+		before := len(index)
+		for id, item := range index {
+			if !olderThan.IsZero() && item.Accessed.Before(olderThan) {
+				delete(index, id)
+			}
+		}
+		cache.evict(index, maxSize)
+		removed = before - len(index)
+	})
+
+	return removed, err
+}
+
+// Clear removes all cached hastes
+func (cache Cache) Clear() error {
+	unlock, err := cache.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	os.Remove(cache.indexPath())
+	if err := os.RemoveAll(filepath.Join(cache.Dir, "blobs")); err != nil {
+		return fmt.Errorf("Error clearing cache: %s", err.Error())
+	}
+
+	return nil
+}
+
+// #region Private
+
+func (cache Cache) indexPath() string {
+	return filepath.Join(cache.Dir, "index.json")
+}
+
+func (cache Cache) blobPath(hash string) string {
+	return filepath.Join(cache.Dir, "blobs", hash)
+}
+
+func (cache Cache) lock() (func(), error) {
+	if err := os.MkdirAll(cache.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("Error writing cache: %s", err.Error())
+	}
+
+	return util.LockFile(cache.indexPath(), lockTimeout)
+}
+
+func (cache Cache) readIndex() (map[string]Item, error) {
+	index := map[string]Item{}
+	content, err := ioutil.ReadFile(cache.indexPath())
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading cache: %s", err.Error())
+	}
+
+	if err := json.Unmarshal(content, &index); err != nil {
+		// a corrupted index only means that everything has to be fetched again
+		return map[string]Item{}, nil
+	}
+
+	return index, nil
+}
+
+func (cache Cache) updateIndex(update func(map[string]Item)) error {
+	unlock, err := cache.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	index, err := cache.readIndex()
+	if err != nil {
+		return err
+	}
+
+	previous := referencedBlobs(index)
+	update(index)
+
+	content, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("Error writing cache: %s", err.Error())
+	}
+
+	temp := cache.indexPath() + ".tmp"
+	if err := ioutil.WriteFile(temp, content, 0600); err != nil {
+		return fmt.Errorf("Error writing cache: %s", err.Error())
+	}
+	if err := os.Rename(temp, cache.indexPath()); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("Error writing cache: %s", err.Error())
+	}
+
+	cache.removeOrphans(previous, index)
+	return nil
+}
+
+// evict removes the least recently used hastes until the referenced blobs fit into maxSize
+func (cache Cache) evict(index map[string]Item, maxSize int64) {
+	if maxSize <= 0 {
+		return
+	}
+
+	items := make([]Item, 0, len(index))
+	for _, item := range index {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Accessed.Before(items[j].Accessed) })
+
+	for len(items) > 0 && blobSize(index) > maxSize {
+		delete(index, itemID(items[0].Server, items[0].Key))
+		items = items[1:]
+	}
+}
+
+// removeOrphans deletes blobs that are not referenced by any haste
+// Blobs that were referenced before the update are deleted right away, unknown blobs only once they are old enough to
+// not belong to a haste that is being stored by another process.
+func (cache Cache) removeOrphans(previous map[string]bool, index map[string]Item) {
+	referenced := referencedBlobs(index)
+
+	blobs, err := ioutil.ReadDir(filepath.Join(cache.Dir, "blobs"))
+	if err != nil {
+		return
+	}
+
+	for _, blob := range blobs {
+		name := blob.Name()
+		if !referenced[name] && (previous[name] || time.Since(blob.ModTime()) > orphanAge) {
+			os.Remove(filepath.Join(cache.Dir, "blobs", name))
+		}
+	}
+}
+
+func referencedBlobs(index map[string]Item) map[string]bool {
+	referenced := map[string]bool{}
+	for _, item := range index {
+		referenced[item.SHA256] = true
+	}
+
+	return referenced
+}
+
+// blobSize sums up the size of all referenced blobs; identical hastes share a blob
+func blobSize(index map[string]Item) int64 {
+	var size int64
+	counted := map[string]bool{}
+	for _, item := range index {
+		if !counted[item.SHA256] {
+			counted[item.SHA256] = true
+			size += item.Size
+		}
+	}
+
+	return size
+}
+
+func itemID(serverURL string, key string) string {
+	return serverURL + "/" + key
+}
+
+func hashBytes(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// #endregion
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jagoe/haste-client-go/server"
+)
+
+// #region Setup
+
+type FakeConditionalGetter struct {
+	haste      string
+	validators server.Validators
+	err        error
+	requests   []server.Validators
+}
+
+func (fake *FakeConditionalGetter) GetConditional(_ string, cached server.Validators, _ *http.Client) (string,
+	server.Validators, bool, error) {
+	fake.requests = append(fake.requests, cached)
+	if fake.err != nil {
+		return "", server.Validators{}, false, fake.err
+	}
+
+	if cached.ETag != "" && cached == fake.validators {
+		return "", cached, true, nil
+	}
+
+	return fake.haste, fake.validators, false, nil
+}
+
+func prepareCache(t *testing.T, maxSize int64) Cache {
+	dir, err := ioutil.TempDir("", "haste-test")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err.Error())
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return Cache{Dir: dir, MaxSize: maxSize}
+}
+
+// #endregion
+
+func TestGetter(t *testing.T) {
+	t.Run("should revalidate cached hastes", func(t *testing.T) {
+		fake := &FakeConditionalGetter{haste: "content", validators: server.Validators{ETag: `"v1"`}}
+		getter := Getter{Getter: fake, Server: "https://hastebin", Cache: prepareCache(t, 0)}
+
+		for i := 0; i < 2; i++ {
+			haste, err := getter.Get("abc", &http.Client{})
+			if err != nil || haste != "content" {
+				t.Fatalf("Expected 'content', got '%s' (%v)", haste, err)
+			}
+		}
+
+		if len(fake.requests) != 2 || fake.requests[0].ETag != "" || fake.requests[1].ETag != `"v1"` {
+			t.Errorf("Expected the second request to send the cached ETag, got %+v", fake.requests)
+		}
+	})
+
+	t.Run("should only serve cached hastes offline", func(t *testing.T) {
+		fake := &FakeConditionalGetter{err: fmt.Errorf("Expected error")}
+		hasteCache := prepareCache(t, 0)
+		hasteCache.Store("https://hastebin", "abc", []byte("cached"), server.Validators{})
+		getter := Getter{Getter: fake, Server: "https://hastebin", Cache: hasteCache, Offline: true}
+
+		haste, err := getter.Get("abc", &http.Client{})
+		if err != nil || haste != "cached" {
+			t.Fatalf("Expected 'cached', got '%s' (%v)", haste, err)
+		}
+
+		if _, err := getter.Get("def", &http.Client{}); err == nil {
+			t.Errorf("Expected an error for a haste that is not cached")
+		}
+
+		if len(fake.requests) != 0 {
+			t.Errorf("Expected no requests in offline mode, got %d", len(fake.requests))
+		}
+	})
+}
+
+func TestCache(t *testing.T) {
+	t.Run("should evict least recently used hastes", func(t *testing.T) {
+		hasteCache := prepareCache(t, 10)
+		hasteCache.Store("s", "a", []byte("aaaa"), server.Validators{})
+		time.Sleep(time.Millisecond)
+		hasteCache.Store("s", "b", []byte("bbbb"), server.Validators{})
+		time.Sleep(time.Millisecond)
+
+		item, _, _ := hasteCache.Lookup("s", "a")
+		hasteCache.Touch(*item)
+		hasteCache.Store("s", "c", []byte("cccc"), server.Validators{})
+
+		if _, _, ok := hasteCache.Lookup("s", "b"); ok {
+			t.Errorf("Expected 'b' to be evicted")
+		}
+
+		for _, key := range []string{"a", "c"} {
+			if _, _, ok := hasteCache.Lookup("s", key); !ok {
+				t.Errorf("Expected '%s' to be cached", key)
+			}
+		}
+	})
+
+	t.Run("should share blobs of identical hastes", func(t *testing.T) {
+		hasteCache := prepareCache(t, 0)
+		hasteCache.Store("s", "a", []byte("same"), server.Validators{})
+		hasteCache.Store("s", "b", []byte("same"), server.Validators{})
+
+		blobs, _ := ioutil.ReadDir(hasteCache.blobPath(""))
+		if len(blobs) != 1 {
+			t.Errorf("Expected a single blob, got %d", len(blobs))
+		}
+	})
+
+	t.Run("should prune and clear hastes", func(t *testing.T) {
+		hasteCache := prepareCache(t, 0)
+		hasteCache.Store("s", "a", []byte("a"), server.Validators{})
+
+		removed, err := hasteCache.Prune(time.Now().Add(-time.Hour), 0)
+		if err != nil || removed != 0 {
+			t.Fatalf("Expected no haste to be pruned, got %d (%v)", removed, err)
+		}
+
+		removed, err = hasteCache.Prune(time.Now().Add(time.Hour), 0)
+		if err != nil || removed != 1 {
+			t.Fatalf("Expected 1 haste to be pruned, got %d (%v)", removed, err)
+		}
+
+		hasteCache.Store("s", "b", []byte("b"), server.Validators{})
+		if err := hasteCache.Clear(); err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		items, _ := hasteCache.List()
+		if len(items) != 0 {
+			t.Errorf("Expected an empty cache, got %+v", items)
+		}
+	})
+}
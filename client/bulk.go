@@ -0,0 +1,79 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/jagoe/haste-client-go/server"
+)
+
+// BulkWorkers is the default number of hastes that are retrieved concurrently
+const BulkWorkers = 8
+
+// BulkResult is the outcome of retrieving one of several hastes
+type BulkResult struct {
+	// Ref is the key or URL the haste has been requested with
+	Ref      string
+	Key      string
+	Document *Document
+	Err      error
+}
+
+// ReadRefs reads haste keys or URLs, one per line
+// Empty lines, lines starting with # and duplicates are skipped.
+func ReadRefs(input io.Reader) ([]string, error) {
+	var refs []string
+	seen := map[string]bool{}
+
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		ref := strings.TrimSpace(scanner.Text())
+		if ref == "" || strings.HasPrefix(ref, "#") || seen[ref] {
+			continue
+		}
+
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading haste list: %s", err.Error())
+	}
+
+	return refs, nil
+}
+
+// FetchAll retrieves several hastes with a bounded number of concurrent workers
+// The results are in the order of the refs; a failed haste does not stop the others.
+func FetchAll(refs []string, workers int, getterFor func(ref string) (server.HasteGetter, string)) []BulkResult {
+	if workers <= 0 {
+		workers = BulkWorkers
+	}
+
+	results := make([]BulkResult, len(refs))
+	indices := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				getter, key := getterFor(refs[i])
+				document, err := Fetch(key, getter)
+				results[i] = BulkResult{Ref: refs[i], Key: key, Document: document, Err: err}
+			}
+		}()
+	}
+
+	for i := range refs {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jagoe/haste-client-go/server"
+)
+
+// #region Setup
+
+type FakeCountingGetter struct {
+	mutex   *sync.Mutex
+	active  *int
+	maximum *int
+}
+
+func (fake FakeCountingGetter) Get(key string, _ *http.Client) (string, error) {
+	fake.mutex.Lock()
+	*fake.active++
+	if *fake.active > *fake.maximum {
+		*fake.maximum = *fake.active
+	}
+	fake.mutex.Unlock()
+
+	defer func() {
+		fake.mutex.Lock()
+		*fake.active--
+		fake.mutex.Unlock()
+	}()
+
+	if strings.HasPrefix(key, "missing") {
+		return "", fmt.Errorf("Error retrieving document %s: 404 Not Found", key)
+	}
+
+	return "content of " + key, nil
+}
+
+// #endregion
+
+func TestReadRefs(t *testing.T) {
+	refs, err := ReadRefs(strings.NewReader("abc\n\n# comment\n  https://hastebin.com/def  \nabc\n"))
+	if err != nil {
+		t.Fatalf("Expected ReadRefs not to return an error, got %s", err.Error())
+	}
+
+	if strings.Join(refs, ",") != "abc,https://hastebin.com/def" {
+		t.Errorf("Expected two deduplicated refs, got %v", refs)
+	}
+}
+
+func TestFetchAll(t *testing.T) {
+	var active, maximum int
+	getter := FakeCountingGetter{mutex: &sync.Mutex{}, active: &active, maximum: &maximum}
+
+	refs := []string{"missing1"}
+	for i := 0; i < 20; i++ {
+		refs = append(refs, fmt.Sprintf("key%d", i))
+	}
+
+	results := FetchAll(refs, 3, func(ref string) (server.HasteGetter, string) { return getter, ref })
+
+	if len(results) != len(refs) || results[0].Err == nil {
+		t.Fatalf("Expected a result for every ref and the first one to fail, got %+v", results[0])
+	}
+
+	for i, result := range results[1:] {
+		if result.Err != nil || string(result.Document.Content) != "content of "+refs[i+1] {
+			t.Errorf("Expected %s to be retrieved in order, got %+v", refs[i+1], result)
+		}
+	}
+
+	if maximum > 3 {
+		t.Errorf("Expected at most 3 concurrent requests, got %d", maximum)
+	}
+}
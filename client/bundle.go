@@ -0,0 +1,217 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	bundleHeader = "=== haste-bundle v1 ==="
+	bundlePrefix = "--- "
+)
+
+// BundleEntry describes a single file within a multi-file haste
+// Size and SHA256 refer to the original file; binary files are stored base64 encoded.
+type BundleEntry struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// BundleFile is a file that has been unpacked from a multi-file haste
+type BundleFile struct {
+	BundleEntry
+	Content []byte
+}
+
+// ExpandPaths expands glob patterns in the provided file arguments
+func ExpandPaths(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		if !strings.ContainsAny(arg, "*?[") {
+			paths = append(paths, arg)
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pattern '%s': %s", arg, err.Error())
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("No files match '%s'", arg)
+		}
+
+		paths = append(paths, matches...)
+	}
+
+	return paths, nil
+}
+
+// MakeBundleFile prepares the content of a file to be packed into a multi-file haste
+func MakeBundleFile(filePath string, content []byte, binaryPolicy BinaryPolicy) (BundleFile, error) {
+	file := BundleFile{
+		BundleEntry: BundleEntry{Path: bundlePath(filePath), Size: int64(len(content)), SHA256: hashBytes(content)},
+		Content:     content,
+	}
+
+	if IsBinary(content) {
+		if binaryPolicy != BinaryEncode {
+			return BundleFile{}, fmt.Errorf("%s is binary and cannot be stored as text; use --binary=encode to upload it anyway",
+				filePath)
+		}
+
+		file.Encoding = "base64"
+	}
+
+	return file, nil
+}
+
+// WriteBundle streams the provided files in the multi-file haste format
+func WriteBundle(files []BundleFile) io.Reader {
+	reader, writer := io.Pipe()
+	go func() {
+		fmt.Fprintln(writer, bundleHeader)
+		for _, file := range files {
+			header, _ := json.Marshal(file.BundleEntry)
+			fmt.Fprintf(writer, "%s%s\n", bundlePrefix, header)
+
+			if file.Encoding == "base64" {
+				encoder := base64.NewEncoder(base64.StdEncoding, writer)
+				encoder.Write(file.Content)
+				encoder.Close()
+			} else {
+				writer.Write(file.Content)
+			}
+			fmt.Fprintln(writer)
+		}
+		writer.Close()
+	}()
+
+	return reader
+}
+
+// IsBundle reports whether a haste is a multi-file haste
+func IsBundle(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(bundleHeader+"\n"))
+}
+
+// ParseBundle unpacks the files of a multi-file haste and verifies their hashes
+func ParseBundle(content []byte) ([]BundleFile, error) {
+	if !IsBundle(content) {
+		return nil, fmt.Errorf("Haste is not a multi-file haste")
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(content[len(bundleHeader)+1:]))
+	var files []BundleFile
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			break
+		}
+		if !strings.HasPrefix(line, bundlePrefix) {
+			return nil, fmt.Errorf("Error reading multi-file haste: unexpected line %q", strings.TrimSpace(line))
+		}
+
+		var file BundleFile
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, bundlePrefix)), &file.BundleEntry); err != nil {
+			return nil, fmt.Errorf("Error reading multi-file haste: %s", err.Error())
+		}
+
+		length := file.Size
+		if file.Encoding == "base64" {
+			length = int64(base64.StdEncoding.EncodedLen(int(file.Size)))
+		}
+
+		stored := make([]byte, length+1)
+		if _, err := io.ReadFull(reader, stored); err != nil || stored[length] != '\n' {
+			return nil, fmt.Errorf("Error reading multi-file haste: %s is truncated", file.Path)
+		}
+		file.Content = stored[:length]
+
+		if file.Encoding == "base64" {
+			file.Content, err = base64.StdEncoding.DecodeString(string(file.Content))
+			if err != nil {
+				return nil, fmt.Errorf("Error reading multi-file haste: %s", err.Error())
+			}
+		}
+
+		if hashBytes(file.Content) != file.SHA256 {
+			return nil, fmt.Errorf("Error reading multi-file haste: %s is corrupted", file.Path)
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// ExtractBundle writes the files of a multi-file haste into a directory
+// Paths that would end up outside of the directory or that occur more than once are refused.
+func ExtractBundle(files []BundleFile, dir string) ([]string, error) {
+	if err := checkDuplicates(files); err != nil {
+		return nil, fmt.Errorf("Refusing to extract multi-file haste: %s", err.Error())
+	}
+
+	var written []string
+	for _, file := range files {
+		target, err := SafeJoin(dir, file.Path)
+		if err != nil {
+			return written, err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return written, fmt.Errorf("Error creating directory: %s", err.Error())
+		}
+
+		if err := ioutil.WriteFile(target, file.Content, 0644); err != nil {
+			return written, fmt.Errorf("Error creating output file: %s", err.Error())
+		}
+		written = append(written, target)
+	}
+
+	return written, nil
+}
+
+// SafeJoin joins a relative slash-separated path to a directory and refuses paths that escape it
+func SafeJoin(dir string, relative string) (string, error) {
+	cleaned := path.Clean("/" + relative)
+	if relative == "" || path.IsAbs(relative) || filepath.IsAbs(relative) || strings.Contains(relative, "\\") ||
+		cleaned != "/"+relative {
+		return "", fmt.Errorf("Refusing to extract unsafe path '%s'", relative)
+	}
+
+	target := filepath.Join(dir, filepath.FromSlash(relative))
+	rel, err := filepath.Rel(dir, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("Refusing to extract unsafe path '%s'", relative)
+	}
+
+	return target, nil
+}
+
+// #region Private
+
+// bundlePath turns a file path into a relative slash-separated path that is safe to extract
+func bundlePath(filePath string) string {
+	cleaned := filepath.ToSlash(filepath.Clean(filePath))
+	cleaned = strings.TrimPrefix(cleaned, filepath.ToSlash(filepath.VolumeName(filePath)))
+	cleaned = strings.TrimLeft(cleaned, "/")
+
+	if cleaned == "" || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return filepath.Base(filePath)
+	}
+
+	return cleaned
+}
+
+// #endregion
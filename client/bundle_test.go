@@ -0,0 +1,120 @@
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+	files := []BundleFile{
+		{BundleEntry: BundleEntry{Path: "main.go"}, Content: []byte("package main\n")},
+		{BundleEntry: BundleEntry{Path: "logs/app.log"}, Content: []byte("--- not a header\nno trailing newline")},
+		{BundleEntry: BundleEntry{Path: "image.png"}, Content: []byte{0x89, 'P', 'N', 'G', 0}},
+	}
+
+	t.Run("should round-trip files", func(t *testing.T) {
+		var prepared []BundleFile
+		for _, file := range files {
+			bundleFile, err := MakeBundleFile(file.Path, file.Content, BinaryEncode)
+			if err != nil {
+				t.Fatalf("Should not have returned error: %s", err.Error())
+			}
+			prepared = append(prepared, bundleFile)
+		}
+
+		content, _ := ioutil.ReadAll(WriteBundle(prepared))
+		if !IsBundle(content) {
+			t.Fatalf("Expected a multi-file haste, got %q", content)
+		}
+
+		parsed, err := ParseBundle(content)
+		if err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		if len(parsed) != len(files) {
+			t.Fatalf("Expected %d files, got %d", len(files), len(parsed))
+		}
+
+		for i, file := range files {
+			if parsed[i].Path != file.Path || !bytes.Equal(parsed[i].Content, file.Content) {
+				t.Errorf("Expected %s to round-trip, got %s: %q", file.Path, parsed[i].Path, parsed[i].Content)
+			}
+		}
+	})
+
+	t.Run("should refuse binary files", func(t *testing.T) {
+		_, err := MakeBundleFile("image.png", files[2].Content, BinaryRefuse)
+		if err == nil {
+			t.Fatalf("Should have returned error")
+		}
+	})
+
+	t.Run("should detect corrupted files", func(t *testing.T) {
+		bundleFile, _ := MakeBundleFile("main.go", files[0].Content, BinaryRefuse)
+		content, _ := ioutil.ReadAll(WriteBundle([]BundleFile{bundleFile}))
+		corrupted := bytes.Replace(content, []byte("package main"), []byte("package evil"), 1)
+
+		_, err := ParseBundle(corrupted)
+		if err == nil || !strings.Contains(err.Error(), "corrupted") {
+			t.Fatalf("Expected a corruption error, got %v", err)
+		}
+	})
+}
+
+func TestBundlePath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"main.go", "main.go"},
+		{"./cmd/root.go", "cmd/root.go"},
+		{"/etc/hosts", "etc/hosts"},
+		{"../secret.txt", "secret.txt"},
+	}
+
+	for _, test := range tests {
+		if path := bundlePath(test.path); path != test.expected {
+			t.Errorf("Expected bundle path of '%s' to be '%s', got '%s'", test.path, test.expected, path)
+		}
+	}
+}
+
+func TestExtractBundleDuplicates(t *testing.T) {
+	files := []BundleFile{{BundleEntry: BundleEntry{Path: "x"}, Content: []byte("a")},
+		{BundleEntry: BundleEntry{Path: "x"}, Content: []byte("b")}}
+
+	if written, err := ExtractBundle(files, t.TempDir()); err == nil || len(written) != 0 {
+		t.Errorf("Expected duplicate paths to be refused before anything is written, got %v", written)
+	}
+}
+
+func TestExtractBundle(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "haste-test")
+	defer os.RemoveAll(dir)
+
+	t.Run("should write files into the directory", func(t *testing.T) {
+		written, err := ExtractBundle([]BundleFile{{BundleEntry: BundleEntry{Path: "a/b.txt"}, Content: []byte("b")}}, dir)
+		if err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		content, _ := ioutil.ReadFile(filepath.Join(dir, "a", "b.txt"))
+		if len(written) != 1 || string(content) != "b" {
+			t.Errorf("Expected a/b.txt to be extracted, got %v", written)
+		}
+	})
+
+	t.Run("should refuse path traversal", func(t *testing.T) {
+		for _, path := range []string{"../evil", "a/../../evil", "/etc/evil", "a\\..\\..\\evil", ""} {
+			_, err := ExtractBundle([]BundleFile{{BundleEntry: BundleEntry{Path: path}}}, dir)
+			if err == nil {
+				t.Errorf("Expected '%s' to be refused", path)
+			}
+		}
+	})
+}
@@ -0,0 +1,286 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/jagoe/haste-client-go/server"
+)
+
+// ManifestType identifies a haste that lists the chunks of a larger document
+const ManifestType = "haste-manifest"
+
+// ChunkWorkers is the number of chunks that are uploaded or downloaded concurrently
+const ChunkWorkers = 4
+
+// Manifest describes a document that has been split into several chunk hastes
+type Manifest struct {
+	Type    string          `json:"type"`
+	Version int             `json:"version"`
+	Size    int64           `json:"size"`
+	SHA256  string          `json:"sha256"`
+	Chunks  []ManifestChunk `json:"chunks"`
+}
+
+// ManifestChunk references a single chunk of a chunked document
+type ManifestChunk struct {
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ChunkState maps the SHA-256 of already uploaded chunks to their haste keys so failed uploads can be resumed
+type ChunkState map[string]string
+
+// ChunkError is returned by CreateChunked if some of the chunks could not be uploaded
+type ChunkError struct {
+	Failed int
+	Total  int
+	State  ChunkState
+	Err    error
+}
+
+func (err *ChunkError) Error() string {
+	return fmt.Sprintf("Error uploading %d of %d chunks: %s", err.Failed, err.Total, err.Err.Error())
+}
+
+// ExceedsLimit checks whether the input is larger than limit bytes without consuming it
+// The returned reader must be used instead of the original input.
+func ExceedsLimit(input io.Reader, limit int) (io.Reader, bool, error) {
+	if limit <= 0 {
+		return input, false, nil
+	}
+
+	buffered := bufio.NewReaderSize(input, limit+1)
+	peeked, err := buffered.Peek(limit + 1)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, false, fmt.Errorf("Error reading input: %s", err.Error())
+	}
+
+	return buffered, len(peeked) > limit, nil
+}
+
+// CreateChunked splits the input at line boundaries into chunks of at most chunkSize bytes, uploads them concurrently
+// and prints the URL of a manifest haste that lists all chunks
+// Chunks that are already contained in state are not uploaded again. Size and checksum of the result refer to the
+// complete content.
+func CreateChunked(input io.Reader, creator server.HasteCreator, serverURL string, chunkSize int, state ChunkState,
+	out io.Writer) (*Result, error) {
+	manifest, err := uploadChunks(input, creator, chunkSize, state)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating manifest: %s", err.Error())
+	}
+
+	result, err := Create(bytes.NewReader(content), creator, serverURL, out)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Size, result.SHA256 = manifest.Size, manifest.SHA256
+	return result, nil
+}
+
+// ParseManifest returns the manifest contained in a haste or false if the haste is not a manifest
+func ParseManifest(haste string) (*Manifest, bool) {
+	trimmed := strings.TrimSpace(haste)
+	if !strings.HasPrefix(trimmed, "{") || !strings.Contains(trimmed, ManifestType) {
+		return nil, false
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal([]byte(trimmed), &manifest); err != nil || manifest.Type != ManifestType {
+		return nil, false
+	}
+
+	return &manifest, true
+}
+
+// Reassemble downloads all chunks of a manifest and verifies their hashes
+func Reassemble(manifest *Manifest, getter server.HasteGetter) (string, error) {
+	chunks := make([]string, len(manifest.Chunks))
+	errs := make([]error, len(manifest.Chunks))
+	indices := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < ChunkWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				chunk := manifest.Chunks[i]
+				content, err := getter.Get(chunk.Key, &http.Client{})
+				if err == nil && hashString(content) != chunk.SHA256 {
+					err = fmt.Errorf("Chunk %s is corrupted: checksum mismatch", chunk.Key)
+				}
+				chunks[i], errs[i] = content, err
+			}
+		}()
+	}
+
+	for i := range manifest.Chunks {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return "", err
+		}
+	}
+
+	content := strings.Join(chunks, "")
+	if manifest.SHA256 != "" && hashString(content) != manifest.SHA256 {
+		return "", fmt.Errorf("Reassembled haste is corrupted: checksum mismatch")
+	}
+
+	return content, nil
+}
+
+// #region Private
+
+func uploadChunks(input io.Reader, creator server.HasteCreator, chunkSize int, state ChunkState) (*Manifest, error) {
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("Invalid chunk size: %d", chunkSize)
+	}
+
+	type job struct {
+		index int
+		data  []byte
+	}
+
+	manifest := &Manifest{Type: ManifestType, Version: 1}
+	var mutex sync.Mutex
+	var failed int
+	var firstErr error
+
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for w := 0; w < ChunkWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				hash := hashBytes(j.data)
+
+				mutex.Lock()
+				key, done := state[hash]
+				mutex.Unlock()
+
+				var err error
+				if !done {
+					key, err = creator.Create(bytes.NewReader(j.data), &http.Client{})
+				}
+
+				mutex.Lock()
+				if err != nil {
+					failed++
+					if firstErr == nil {
+						firstErr = err
+					}
+				} else {
+					state[hash] = key
+				}
+				manifest.Chunks[j.index] = ManifestChunk{Key: key, Size: int64(len(j.data)), SHA256: hash}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	total := sha256.New()
+	readErr := splitLines(io.TeeReader(input, total), chunkSize, func(data []byte) {
+		mutex.Lock()
+		index := len(manifest.Chunks)
+		manifest.Chunks = append(manifest.Chunks, ManifestChunk{})
+		mutex.Unlock()
+
+		manifest.Size += int64(len(data))
+		jobs <- job{index: index, data: data}
+	})
+	close(jobs)
+	wg.Wait()
+
+	if readErr != nil {
+		return nil, fmt.Errorf("Error reading input: %s", readErr.Error())
+	}
+
+	if failed > 0 {
+		return nil, &ChunkError{Failed: failed, Total: len(manifest.Chunks), State: state, Err: firstErr}
+	}
+
+	manifest.SHA256 = hex.EncodeToString(total.Sum(nil))
+	return manifest, nil
+}
+
+// splitLines reads the input and emits chunks of at most chunkSize bytes that end at line boundaries
+// Lines that are longer than chunkSize are split between characters, since the server stores every chunk as text.
+func splitLines(input io.Reader, chunkSize int, emit func([]byte)) error {
+	reader := bufio.NewReader(input)
+	chunk := make([]byte, 0, chunkSize)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		for len(line) > 0 {
+			if len(chunk)+len(line) <= chunkSize {
+				chunk = append(chunk, line...)
+				line = nil
+				break
+			}
+
+			if len(chunk) > 0 {
+				emit(chunk)
+				chunk = make([]byte, 0, chunkSize)
+				continue
+			}
+
+			cut := chunkSize
+			for i := chunkSize; i > 0 && i > chunkSize-utf8.UTFMax; i-- {
+				if utf8.RuneStart(line[i]) {
+					cut = i
+					break
+				}
+			}
+
+			emit(line[:cut])
+			line = line[cut:]
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(chunk) > 0 {
+		emit(chunk)
+	}
+
+	return nil
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hashString(data string) string {
+	return hashBytes([]byte(data))
+}
+
+// #endregion
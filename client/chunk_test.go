@@ -0,0 +1,200 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// #region Setup
+
+type FakeMemoryServer struct {
+	mutex  sync.Mutex
+	hastes map[string]string
+	failOn string
+}
+
+func NewFakeMemoryServer() *FakeMemoryServer {
+	return &FakeMemoryServer{hastes: map[string]string{}}
+}
+
+func (fake *FakeMemoryServer) Create(content io.Reader, _ *http.Client) (string, error) {
+	data, _ := ioutil.ReadAll(content)
+	if fake.failOn != "" && strings.Contains(string(data), fake.failOn) {
+		return "", fmt.Errorf("Expected error")
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	key := fmt.Sprintf("k%d", len(fake.hastes))
+	fake.hastes[key] = string(data)
+
+	return key, nil
+}
+
+func (fake *FakeMemoryServer) Get(key string, _ *http.Client) (string, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	haste, ok := fake.hastes[key]
+	if !ok {
+		return "", fmt.Errorf("Error retrieving document %s: 404 Not Found", key)
+	}
+
+	return haste, nil
+}
+
+// #endregion
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		title  string
+		input  string
+		size   int
+		chunks []string
+	}{
+		{"Empty input", "", 5, nil},
+		{"Input fits", "abc\n", 5, []string{"abc\n"}},
+		{"Split at line boundaries", "ab\ncd\nef\n", 6, []string{"ab\ncd\n", "ef\n"}},
+		{"Split long lines", "abcdefgh\nij", 3, []string{"abc", "def", "gh\n", "ij"}},
+		{"Split long lines between characters", "aäöü\n", 4, []string{"aä", "öü", "\n"}},
+	}
+
+	for _, test := range tests {
+		var chunks []string
+		err := splitLines(bytes.NewBufferString(test.input), test.size, func(chunk []byte) {
+			chunks = append(chunks, string(chunk))
+		})
+
+		if err != nil {
+			t.Fatalf("%s: Should not have returned error: %s", test.title, err.Error())
+		}
+
+		if fmt.Sprint(chunks) != fmt.Sprint(test.chunks) {
+			t.Errorf("%s: Expected chunks %q, got %q", test.title, test.chunks, chunks)
+		}
+	}
+}
+
+func TestSplitLongNonASCIILine(t *testing.T) {
+	input := strings.Repeat("größer als ein Chunk – ", 200) + "\n"
+
+	var joined strings.Builder
+	err := splitLines(strings.NewReader(input), 100, func(chunk []byte) {
+		if !utf8.Valid(chunk) || len(chunk) > 100 {
+			t.Errorf("Expected chunks of valid UTF-8 within the chunk size, got %q", chunk)
+		}
+		joined.Write(chunk)
+	})
+
+	if err != nil || joined.String() != input {
+		t.Errorf("Expected the chunks to reproduce the input, got %v", err)
+	}
+}
+
+func TestExceedsLimit(t *testing.T) {
+	for _, test := range []struct {
+		input   string
+		exceeds bool
+	}{{"1234", false}, {"12345", true}} {
+		input, exceeds, err := ExceedsLimit(bytes.NewBufferString(test.input), 4)
+		if err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		if exceeds != test.exceeds {
+			t.Errorf("Expected %q to exceed the limit: %t", test.input, test.exceeds)
+		}
+
+		content, _ := ioutil.ReadAll(input)
+		if string(content) != test.input {
+			t.Errorf("Expected input to be preserved as %q, got %q", test.input, content)
+		}
+	}
+}
+
+func TestCreateChunked(t *testing.T) {
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("line %03d\n", i))
+	}
+	content := strings.Join(lines, "")
+
+	t.Run("should create a manifest that can be reassembled", func(t *testing.T) {
+		fake := NewFakeMemoryServer()
+		buffer := bytes.NewBufferString("")
+
+		_, err := CreateChunked(bytes.NewBufferString(content), fake, "hastebin.local", 100, ChunkState{}, buffer)
+		if err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		key := strings.TrimPrefix(buffer.String(), "hastebin.local/")
+		manifest, ok := ParseManifest(fake.hastes[key])
+		if !ok {
+			t.Fatalf("Expected haste %s to be a manifest, got %q", key, fake.hastes[key])
+		}
+
+		if len(manifest.Chunks) != 10 {
+			t.Errorf("Expected 10 chunks, got %d", len(manifest.Chunks))
+		}
+
+		output := bytes.NewBufferString("")
+		if err := Get(key, fake, output); err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		if output.String() != content {
+			t.Errorf("Expected reassembled haste to equal the original content")
+		}
+	})
+
+	t.Run("should return resumable state if chunks fail", func(t *testing.T) {
+		fake := NewFakeMemoryServer()
+		fake.failOn = "broken"
+		input := content + "broken\n"
+
+		_, err := CreateChunked(bytes.NewBufferString(input), fake, "hastebin.local", 100, ChunkState{}, nil)
+		chunkErr, ok := err.(*ChunkError)
+		if !ok {
+			t.Fatalf("Expected a ChunkError, got %v", err)
+		}
+
+		if chunkErr.Failed != 1 || len(chunkErr.State) != 9 {
+			t.Fatalf("Expected 1 failed and 9 uploaded chunks, got %d and %d", chunkErr.Failed, len(chunkErr.State))
+		}
+
+		fake.failOn = ""
+		uploaded := len(fake.hastes)
+		buffer := bytes.NewBufferString("")
+		_, err = CreateChunked(bytes.NewBufferString(input), fake, "hastebin.local", 100, chunkErr.State, buffer)
+		if err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		if len(fake.hastes) != uploaded+2 {
+			t.Errorf("Expected only the failed chunk and the manifest to be uploaded, got %d new hastes",
+				len(fake.hastes)-uploaded)
+		}
+	})
+}
+
+func TestReassemble(t *testing.T) {
+	t.Run("should return an error if a chunk is corrupted", func(t *testing.T) {
+		fake := NewFakeMemoryServer()
+		fake.hastes["k0"] = "tampered"
+		manifest := &Manifest{Type: ManifestType, Chunks: []ManifestChunk{{Key: "k0", SHA256: hashString("original")}}}
+
+		_, err := Reassemble(manifest, fake)
+
+		expectedError := "Chunk k0 is corrupted: checksum mismatch"
+		if err == nil || err.Error() != expectedError {
+			t.Fatalf("Expected '%s', got '%v'", expectedError, err)
+		}
+	})
+}
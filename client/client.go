@@ -1,31 +1,132 @@
 package client
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
 
 	"github.com/jagoe/haste-client-go/server"
 )
 
+// Result describes a haste that has been created on the server
+type Result struct {
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	RawURL string `json:"rawUrl"`
+	Server string `json:"server"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// ViewURL highlights the haste by its extension, if there is one
+	ViewURL   string `json:"viewUrl"`
+	Extension string `json:"extension,omitempty"`
+}
+
+// Document is a haste that has been retrieved from the server and decoded
+type Document struct {
+	Key     string
+	Content []byte
+	// Envelope is set if the haste contained binary content
+	Envelope *Envelope
+	// Size and SHA256 describe the haste as it is stored on the server, like the Result of creating it; they differ
+	// from Content if a binary envelope has been decoded
+	Size   int64
+	SHA256 string
+}
+
+// Fetch retrieves a haste from the server, reassembles chunked hastes and decodes binary envelopes
+func Fetch(key string, getter server.HasteGetter) (*Document, error) {
+	haste, err := getter.Get(key, &http.Client{})
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest, ok := ParseManifest(haste); ok {
+		haste, err = Reassemble(manifest, getter)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	envelope, content, ok, err := ParseEnvelope(haste)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		content = []byte(haste)
+	}
+
+	return &Document{Key: key, Content: content, Envelope: envelope, Size: int64(len(haste)), SHA256: hashString(haste)},
+		nil
+}
+
 // Get retrieves a haste from the server and prints it to STDOUT or into a file
 func Get(key string, getter server.HasteGetter, out io.Writer) error {
-	haste, err := getter.Get(key, &http.Client{})
+	document, err := Fetch(key, getter)
 	if err != nil {
 		return err
 	}
 
-	fmt.Fprint(out, haste)
+	return Write(document, out)
+}
+
+// Write prints the content of a fetched haste
+func Write(document *Document, out io.Writer) error {
+	if _, err := out.Write(document.Content); err != nil {
+		return fmt.Errorf("Error writing haste: %s", err.Error())
+	}
+
 	return nil
 }
 
 // Create a new haste on the server and print an identifier to STDOUT
-func Create(input io.Reader, creator server.HasteCreator, serverURL string, out io.Writer) error {
-	key, err := creator.Create(input, &http.Client{})
+func Create(input io.Reader, creator server.HasteCreator, serverURL string, out io.Writer) (*Result, error) {
+	result, err := createHaste(input, creator, serverURL)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fmt.Fprintf(out, "%s/%s", serverURL, key)
-	return nil
+	fmt.Fprint(out, result.URL)
+	return result, nil
+}
+
+// #region Private
+
+// createHaste creates a haste and calculates size and checksum of the uploaded content
+func createHaste(input io.Reader, creator server.HasteCreator, serverURL string) (*Result, error) {
+	digest := &digestReader{reader: input, hash: sha256.New()}
+	key, err := creator.Create(digest, &http.Client{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Key:    key,
+		URL:    fmt.Sprintf("%s/%s", serverURL, key),
+		RawURL: fmt.Sprintf("%s/raw/%s", serverURL, key),
+		Server: serverURL,
+		Size:   digest.size,
+		SHA256: hex.EncodeToString(digest.hash.Sum(nil)),
+	}
+
+	return result.WithExtension(""), nil
+}
+
+// digestReader counts and hashes everything that is read through it
+type digestReader struct {
+	reader io.Reader
+	hash   hash.Hash
+	size   int64
 }
+
+func (digest *digestReader) Read(p []byte) (int, error) {
+	n, err := digest.reader.Read(p)
+	digest.hash.Write(p[:n])
+	digest.size += int64(n)
+
+	return n, err
+}
+
+// #endregion
@@ -3,7 +3,11 @@ package client
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 // FileOpener is an interface that describes opening a file
@@ -25,31 +29,173 @@ func (OsFileOpener) Open(name string) (*os.File, error) {
 	return os.Open(name)
 }
 
+// OutputMode determines what happens if the output file of a fetched haste already exists
+type OutputMode string
+
+const (
+	// OutputCreate refuses to replace existing files
+	OutputCreate OutputMode = "create"
+	// OutputForce replaces existing files
+	OutputForce OutputMode = "force"
+	// OutputNoClobber keeps existing files and skips the haste
+	OutputNoClobber OutputMode = "no-clobber"
+	// OutputAppend appends the haste to existing files
+	OutputAppend OutputMode = "append"
+)
+
+// OutputExistsError is returned if the output file exists and OutputCreate does not allow to replace it
+type OutputExistsError struct {
+	Path string
+}
+
+func (err *OutputExistsError) Error() string {
+	return fmt.Sprintf("Output file %s already exists; use --force to replace it", err.Path)
+}
+
+// Output is the destination of a fetched haste
+// Output files are written to a temporary file next to them that replaces them on Commit, so a failed download never
+// leaves a partial or truncated file behind. Call Abort to discard the output on errors.
+type Output struct {
+	io.Writer
+	// Skipped is set if the output file exists and is kept; everything written is discarded
+	Skipped bool
+
+	file *os.File
+	path string
+	temp string
+}
+
 // SetupGetOutput prepares the output stream for client.Get based on a filepath that the user did or did not provide
-func SetupGetOutput(filepath string, fileOpener FileOpener, stdout io.Writer) (io.Writer, error) {
+// New files are created with permissions that respect the umask; replaced files keep their permissions.
+func SetupGetOutput(filepath string, mode OutputMode, fileOpener FileOpener, stdout io.Writer) (*Output, error) {
 	if filepath == "" {
-		return stdout, nil
+		return &Output{Writer: stdout}, nil
 	}
 
-	file, err := fileOpener.OpenFile(filepath, os.O_CREATE, os.ModePerm)
+	info, statErr := os.Stat(filepath)
+	exists := statErr == nil
+	switch {
+	case exists && info.IsDir():
+		return nil, fmt.Errorf("Error creating output file: %s is a directory", filepath)
+	case exists && mode == OutputCreate:
+		return nil, &OutputExistsError{Path: filepath}
+	case exists && mode == OutputNoClobber:
+		return &Output{Writer: ioutil.Discard, Skipped: true}, nil
+	case mode == OutputAppend:
+		file, err := fileOpener.OpenFile(filepath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating output file: %s", err.Error())
+		}
+
+		return &Output{Writer: file, file: file, path: filepath}, nil
+	}
+
+	file, temp, err := createTempFile(filepath, fileOpener)
 	if err != nil {
 		return nil, fmt.Errorf("Error creating output file: %s", err.Error())
 	}
 
-	return file, nil
+	if exists {
+		os.Chmod(temp, info.Mode().Perm())
+	}
+
+	return &Output{Writer: file, file: file, path: filepath, temp: temp}, nil
+}
+
+// Commit completes the output file by replacing the target with the temporary file
+func (output *Output) Commit() error {
+	if output.file == nil {
+		return nil
+	}
+
+	err := output.file.Sync()
+	if closeErr := output.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && output.temp != "" {
+		err = os.Rename(output.temp, output.path)
+	}
+
+	if err != nil {
+		if output.temp != "" {
+			os.Remove(output.temp)
+		}
+
+		return fmt.Errorf("Error writing output file: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Abort discards the temporary file, so the target is left untouched
+func (output *Output) Abort() {
+	if output.file == nil {
+		return
+	}
 
+	output.file.Close()
+	if output.temp != "" {
+		os.Remove(output.temp)
+	}
+}
+
+// ResolveOutputPath determines the file a fetched haste is saved to
+// If the provided path is a directory, the original filename of a binary haste or else its key is used within it.
+func ResolveOutputPath(path string, document *Document) string {
+	if path == "" {
+		return ""
+	}
+
+	info, err := os.Stat(path)
+	isDir := err == nil && info.IsDir()
+	if !isDir && !strings.HasSuffix(path, string(filepath.Separator)) && !strings.HasSuffix(path, "/") {
+		return path
+	}
+
+	name := filepath.Base(document.Key)
+	if document.Envelope != nil && document.Envelope.Filename != "" {
+		name = filepath.Base(document.Envelope.Filename)
+	}
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		// never resolve to the directory itself or its parent
+		name = "haste"
+	}
+
+	return filepath.Join(path, name)
 }
 
 // SetupCreateInput determines where client.Create gets its input from
-func SetupCreateInput(filepath string, fileOpener FileOpener, stdin io.Reader) (io.Reader, error) {
-	if filepath == "" {
-		return stdin, nil
+// Binary input is either refused or wrapped in an envelope, depending on the binary policy.
+func SetupCreateInput(path string, binaryPolicy BinaryPolicy, fileOpener FileOpener, stdin io.Reader) (io.Reader, error) {
+	if path == "" {
+		return DetectBinary(stdin, "", binaryPolicy)
 	}
 
-	file, err := fileOpener.Open(filepath)
+	file, err := fileOpener.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("Error reading input file: %s", err.Error())
 	}
 
-	return file, nil
+	return DetectBinary(file, path, binaryPolicy)
 }
+
+// #region Private
+
+// createTempFile creates a temporary file next to the target, so it can be renamed atomically
+// It is created with os.OpenFile instead of ioutil.TempFile, so its permissions respect the umask.
+func createTempFile(target string, fileOpener FileOpener) (*os.File, string, error) {
+	dir, name := filepath.Split(target)
+	for attempt := 0; ; attempt++ {
+		temp := filepath.Join(dir, fmt.Sprintf(".%s.%d-%d.tmp", name, os.Getpid(), rand.Int63()))
+		file, err := fileOpener.OpenFile(temp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+		if err == nil {
+			return file, temp, nil
+		}
+
+		if !os.IsExist(err) || attempt >= 10 {
+			return nil, "", err
+		}
+	}
+}
+
+// #endregion
@@ -1,8 +1,12 @@
 package client
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -29,14 +33,14 @@ func (fake FakeFileOpener) Open(name string) (*os.File, error) {
 
 func TestSetupGetOutput(t *testing.T) {
 	t.Run("should return STDOUT if filepath is empty", func(t *testing.T) {
-		out, err := SetupGetOutput("", nil, os.Stdout)
+		out, err := SetupGetOutput("", OutputCreate, nil, os.Stdout)
 
 		if err != nil {
 			t.Fatalf("Should not have returned error: %s", err.Error())
 		}
 
-		if out != os.Stdout {
-			t.Fatalf("Output is not STDOUT, but %v", out)
+		if out.Writer != os.Stdout {
+			t.Fatalf("Output is not STDOUT, but %v", out.Writer)
 		}
 	})
 
@@ -44,7 +48,7 @@ func TestSetupGetOutput(t *testing.T) {
 		fileError := "Expected error"
 		expectedError := fmt.Sprintf("Error creating output file: %s", fileError)
 		fakeFileOpener := FakeFileOpener{err: fmt.Errorf(fileError)}
-		_, err := SetupGetOutput("invalid", fakeFileOpener, nil)
+		_, err := SetupGetOutput("invalid", OutputCreate, fakeFileOpener, nil)
 
 		if err == nil {
 			t.Fatalf("Should have returned error")
@@ -55,31 +59,97 @@ func TestSetupGetOutput(t *testing.T) {
 		}
 	})
 
-	t.Run("should return file if filepath is valid", func(t *testing.T) {
-		expectedFile := &os.File{}
-		fakeFileOpener := FakeFileOpener{file: expectedFile}
-		file, err := SetupGetOutput("valid", fakeFileOpener, nil)
+	t.Run("should only create the file on commit", func(t *testing.T) {
+		dir, _ := ioutil.TempDir("", "haste-test")
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "haste.txt")
 
+		out, err := SetupGetOutput(path, OutputCreate, OsFileOpener{}, nil)
 		if err != nil {
 			t.Fatalf("Should not have returned error: %s", err.Error())
 		}
 
-		if file != expectedFile {
-			t.Fatalf("Expected '%v', but got '%v'", expectedFile, file)
+		out.Write([]byte("content"))
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("Expected the file not to exist before commit")
+		}
+
+		if err := out.Commit(); err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		content, _ := ioutil.ReadFile(path)
+		info, _ := os.Stat(path)
+		if string(content) != "content" || info.Mode().Perm()&0111 != 0 {
+			t.Errorf("Expected the committed file without execute permissions, got '%s' (%s)", content, info.Mode())
+		}
+
+		if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+			t.Errorf("Expected the temporary file to be gone, got %d files", len(files))
+		}
+	})
+
+	t.Run("should handle existing files depending on the mode", func(t *testing.T) {
+		dir, _ := ioutil.TempDir("", "haste-test")
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "haste.txt")
+
+		tests := []struct {
+			mode     OutputMode
+			expected string
+		}{{OutputForce, "new"}, {OutputNoClobber, "a much longer old content"}, {OutputAppend, "a much longer old contentnew"}}
+
+		for _, test := range tests {
+			ioutil.WriteFile(path, []byte("a much longer old content"), 0640)
+
+			out, err := SetupGetOutput(path, test.mode, OsFileOpener{}, nil)
+			if err != nil {
+				t.Fatalf("%s: Should not have returned error: %s", test.mode, err.Error())
+			}
+			out.Write([]byte("new"))
+			out.Commit()
+
+			content, _ := ioutil.ReadFile(path)
+			if string(content) != test.expected {
+				t.Errorf("%s: Expected '%s', got '%s'", test.mode, test.expected, content)
+			}
+		}
+
+		_, err := SetupGetOutput(path, OutputCreate, OsFileOpener{}, nil)
+		if _, ok := err.(*OutputExistsError); !ok {
+			t.Errorf("Expected an OutputExistsError, got %v", err)
+		}
+	})
+
+	t.Run("should leave the target untouched on abort", func(t *testing.T) {
+		dir, _ := ioutil.TempDir("", "haste-test")
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "haste.txt")
+		ioutil.WriteFile(path, []byte("old"), 0644)
+
+		out, _ := SetupGetOutput(path, OutputForce, OsFileOpener{}, nil)
+		out.Write([]byte("partial"))
+		out.Abort()
+
+		content, _ := ioutil.ReadFile(path)
+		files, _ := ioutil.ReadDir(dir)
+		if string(content) != "old" || len(files) != 1 {
+			t.Errorf("Expected only the old file to remain, got '%s' and %d files", content, len(files))
 		}
 	})
 }
 
 func TestSetupCreateInput(t *testing.T) {
-	t.Run("should return STDIN if filepath is empty", func(t *testing.T) {
-		out, err := SetupCreateInput("", nil, os.Stdin)
+	t.Run("should read from STDIN if filepath is empty", func(t *testing.T) {
+		in, err := SetupCreateInput("", BinaryRefuse, nil, bytes.NewBufferString("from stdin"))
 
 		if err != nil {
 			t.Fatalf("Should not have returned error: %s", err.Error())
 		}
 
-		if out != os.Stdin {
-			t.Fatalf("Output is not STDIN, but %v", out)
+		content, _ := ioutil.ReadAll(in)
+		if string(content) != "from stdin" {
+			t.Fatalf("Input is not STDIN, but %q", content)
 		}
 	})
 
@@ -87,7 +157,7 @@ func TestSetupCreateInput(t *testing.T) {
 		fileError := "Expected error"
 		expectedError := fmt.Sprintf("Error reading input file: %s", fileError)
 		fakeFileOpener := FakeFileOpener{err: fmt.Errorf(fileError)}
-		_, err := SetupCreateInput("invalid", fakeFileOpener, nil)
+		_, err := SetupCreateInput("invalid", BinaryRefuse, fakeFileOpener, nil)
 
 		if err == nil {
 			t.Fatalf("Should have returned error")
@@ -98,17 +168,91 @@ func TestSetupCreateInput(t *testing.T) {
 		}
 	})
 
-	t.Run("should return file if filepath is valid", func(t *testing.T) {
-		expectedFile := &os.File{}
+	t.Run("should read from file if filepath is valid", func(t *testing.T) {
+		expectedFile := tempFile(t, []byte("from file"))
 		fakeFileOpener := FakeFileOpener{file: expectedFile}
-		file, err := SetupCreateInput("valid", fakeFileOpener, nil)
+		in, err := SetupCreateInput("valid", BinaryRefuse, fakeFileOpener, nil)
+
+		if err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		content, _ := ioutil.ReadAll(in)
+		if string(content) != "from file" {
+			t.Fatalf("Expected file contents, but got %q", content)
+		}
+	})
+
+	t.Run("should refuse binary input", func(t *testing.T) {
+		_, err := SetupCreateInput("", BinaryRefuse, nil, bytes.NewBuffer([]byte{0x89, 'P', 'N', 'G', 0}))
+
+		if err == nil {
+			t.Fatalf("Should have returned error")
+		}
+	})
+
+	t.Run("should encode binary input", func(t *testing.T) {
+		binary := []byte{0x89, 'P', 'N', 'G', 0, 0xff}
+		fakeFileOpener := FakeFileOpener{file: tempFile(t, binary)}
+		in, err := SetupCreateInput("dir/image.png", BinaryEncode, fakeFileOpener, nil)
 
 		if err != nil {
 			t.Fatalf("Should not have returned error: %s", err.Error())
 		}
 
-		if file != expectedFile {
-			t.Fatalf("Expected '%v', but got '%v'", expectedFile, file)
+		content, _ := ioutil.ReadAll(in)
+		envelope, decoded, ok, err := ParseEnvelope(string(content))
+		if !ok || err != nil {
+			t.Fatalf("Expected an envelope, got %q (%v)", content, err)
+		}
+
+		if !bytes.Equal(decoded, binary) {
+			t.Errorf("Expected envelope to contain %v, got %v", binary, decoded)
+		}
+
+		if envelope.Filename != "image.png" || envelope.MIME != "image/png" || envelope.Size != int64(len(binary)) {
+			t.Errorf("Unexpected envelope metadata: %+v", envelope)
 		}
 	})
 }
+
+func TestResolveOutputPath(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "haste-test")
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		title    string
+		path     string
+		document Document
+		expected string
+	}{
+		{"No path", "", Document{Key: "abc"}, ""},
+		{"File path", "./file", Document{Key: "abc"}, "./file"},
+		{"Directory with text haste", dir, Document{Key: "abc"}, filepath.Join(dir, "abc")},
+		{"Directory with binary haste", dir, Document{Key: "abc", Envelope: &Envelope{Filename: "../image.png"}},
+			filepath.Join(dir, "image.png")},
+		{"Directory with parent key", dir, Document{Key: ".."}, filepath.Join(dir, "haste")},
+		{"Current directory with binary haste", ".", Document{Key: "abc", Envelope: &Envelope{Filename: "image.png"}},
+			"image.png"},
+	}
+
+	for _, test := range tests {
+		path := ResolveOutputPath(test.path, &test.document)
+		if path != test.expected {
+			t.Errorf("%s: Expected '%s', got '%s'", test.title, test.expected, path)
+		}
+	}
+}
+
+func tempFile(t *testing.T, content []byte) *os.File {
+	file, err := ioutil.TempFile("", "haste-test")
+	if err != nil {
+		t.Fatalf("Error creating temp file: %s", err.Error())
+	}
+	t.Cleanup(func() { os.Remove(file.Name()) })
+
+	file.Write(content)
+	file.Seek(0, io.SeekStart)
+
+	return file
+}
@@ -63,7 +63,7 @@ func TestGet(t *testing.T) {
 func TestCreate(t *testing.T) {
 	t.Run("should log error", func(t *testing.T) {
 		expectedError := "Expected error"
-		err := Create(bytes.NewBufferString(""), FakeCreator{err: fmt.Errorf(expectedError)}, "", bytes.NewBufferString(""))
+		_, err := Create(bytes.NewBufferString(""), FakeCreator{err: fmt.Errorf(expectedError)}, "", bytes.NewBufferString(""))
 
 		if err == nil {
 			t.Error("Expected Create to return an error")
@@ -80,12 +80,16 @@ func TestCreate(t *testing.T) {
 		hasteKey := "abcdef"
 		expectedHasteURL := fmt.Sprintf("%s/%s", serverURL, hasteKey)
 
-		err := Create(bytes.NewBufferString(""), FakeCreator{hasteKey: hasteKey}, serverURL, buffer)
+		result, err := Create(bytes.NewBufferString(""), FakeCreator{hasteKey: hasteKey}, serverURL, buffer)
 
 		if err != nil {
 			t.Errorf("Expected Create not to return an error, got %s", err.Error())
 		}
 
+		if result.Key != hasteKey || result.URL != expectedHasteURL {
+			t.Errorf("Expected Create to return key '%s' and URL '%s', got %+v", hasteKey, expectedHasteURL, result)
+		}
+
 		hasteURL := buffer.String()
 		if hasteURL != expectedHasteURL {
 			t.Errorf("Expected Create to return '%s' as haste URL, got '%s'", expectedHasteURL, hasteURL)
@@ -0,0 +1,347 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jagoe/haste-client-go/server"
+	"github.com/jagoe/haste-client-go/util"
+)
+
+const indexHeader = "=== haste-index v1 ==="
+
+// IgnoreFiles are read in every walked directory to determine which files are skipped
+var IgnoreFiles = []string{".gitignore", ".hasteignore"}
+
+// SkippedFile is a file within a directory that has not been uploaded
+type SkippedFile struct {
+	Path   string
+	Reason string
+}
+
+// IndexEntry links a file of a directory upload to the haste containing it
+type IndexEntry struct {
+	BundleEntry
+	URL string
+}
+
+// LoadFiles reads the provided files and walks the provided directories
+// Files within directories are skipped if they are ignored, binary or larger than maxSize.
+// Files are stored relative to the common parent directory of all paths, so the local directory layout is not
+// published. Paths that would be stored more than once are refused.
+func LoadFiles(paths []string, maxSize int64, binaryPolicy BinaryPolicy) ([]BundleFile, []SkippedFile, error) {
+	var files []BundleFile
+	var skipped []SkippedFile
+
+	base := commonRoot(paths)
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error reading input file: %s", err.Error())
+		}
+
+		if !info.IsDir() {
+			content, err := ioutil.ReadFile(root)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Error reading input file: %s", err.Error())
+			}
+
+			file, err := MakeBundleFile(relativePath(base, root), content, binaryPolicy)
+			if err != nil {
+				return nil, nil, err
+			}
+			files = append(files, file)
+			continue
+		}
+
+		dirFiles, dirSkipped, err := walkDirectory(root, relativePath(base, root), maxSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, dirFiles...)
+		skipped = append(skipped, dirSkipped...)
+	}
+
+	if err := checkDuplicates(files); err != nil {
+		return nil, nil, err
+	}
+
+	return files, skipped, nil
+}
+
+// CreateIndex uploads every file as a separate haste and creates an index haste that links all of them
+func CreateIndex(files []BundleFile, creator server.HasteCreator, serverURL string) (*Result, []IndexEntry, error) {
+	entries := make([]IndexEntry, len(files))
+	errs := make([]error, len(files))
+	indices := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < ChunkWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				var content io.Reader = bytes.NewReader(files[i].Content)
+				if files[i].Encoding == "base64" {
+					content = EncodeEnvelope(content, Envelope{Filename: path.Base(files[i].Path), MIME: "application/octet-stream"})
+				}
+
+				key, err := creator.Create(content, &http.Client{})
+				entries[i] = IndexEntry{BundleEntry: files[i].BundleEntry, URL: fmt.Sprintf("%s/%s", serverURL, key)}
+				errs[i] = err
+			}
+		}()
+	}
+
+	for i := range files {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error uploading %s: %s", files[i].Path, err.Error())
+		}
+	}
+
+	var index strings.Builder
+	fmt.Fprintln(&index, indexHeader)
+	for _, entry := range entries {
+		fmt.Fprintf(&index, "%s\t%d\t%s\t%s\n", entry.URL, entry.Size, entry.SHA256, entry.Path)
+	}
+
+	result, err := createHaste(strings.NewReader(index.String()), creator, serverURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result, entries, nil
+}
+
+// IsIndex reports whether a haste is an index of a directory upload
+func IsIndex(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(indexHeader+"\n"))
+}
+
+// ParseIndex reads the entries of an index haste
+func ParseIndex(content []byte) ([]IndexEntry, error) {
+	if !IsIndex(content) {
+		return nil, fmt.Errorf("Haste is not an index haste")
+	}
+
+	var entries []IndexEntry
+	scanner := bufio.NewScanner(bytes.NewReader(content[len(indexHeader)+1:]))
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+
+		fields := strings.SplitN(scanner.Text(), "\t", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("Error reading index haste: unexpected line %q", scanner.Text())
+		}
+
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading index haste: %s", err.Error())
+		}
+
+		entries = append(entries, IndexEntry{
+			BundleEntry: BundleEntry{Path: fields[3], Size: size, SHA256: fields[2]},
+			URL:         fields[0],
+		})
+	}
+
+	return entries, nil
+}
+
+// FetchIndex downloads all files that are linked from an index haste and verifies their hashes
+// getterFor returns the getter and key for the URL of an entry or an error if the entry must not be retrieved.
+func FetchIndex(entries []IndexEntry, getterFor func(url string) (server.HasteGetter, string, error)) ([]BundleFile,
+	error) {
+	files := make([]BundleFile, len(entries))
+	for i, entry := range entries {
+		getter, key, err := getterFor(entry.URL)
+		if err != nil {
+			return nil, fmt.Errorf("Error retrieving %s: %s", entry.Path, err.Error())
+		}
+
+		document, err := Fetch(key, getter)
+		if err != nil {
+			return nil, fmt.Errorf("Error retrieving %s: %s", entry.Path, err.Error())
+		}
+
+		if hashBytes(document.Content) != entry.SHA256 {
+			return nil, fmt.Errorf("Error retrieving %s: checksum mismatch", entry.Path)
+		}
+
+		files[i] = BundleFile{BundleEntry: entry.BundleEntry, Content: document.Content}
+	}
+
+	return files, nil
+}
+
+// PrintTree prints the provided paths as an indented tree, followed by an optional note for each path
+func PrintTree(out io.Writer, paths []string, notes map[string]string) {
+	sorted := append([]string{}, paths...)
+	sort.Strings(sorted)
+
+	var previous []string
+	for _, filePath := range sorted {
+		parts := strings.Split(filePath, "/")
+		dirs := parts[:len(parts)-1]
+
+		common := 0
+		for common < len(dirs) && common < len(previous) && dirs[common] == previous[common] {
+			common++
+		}
+		for depth := common; depth < len(dirs); depth++ {
+			fmt.Fprintf(out, "%s%s/\n", strings.Repeat("  ", depth), dirs[depth])
+		}
+		previous = dirs
+
+		line := strings.Repeat("  ", len(dirs)) + parts[len(parts)-1]
+		if note := notes[filePath]; note != "" {
+			line += "  " + note
+		}
+		fmt.Fprintln(out, line)
+	}
+}
+
+// #region Private
+
+// commonRoot returns the deepest directory that contains all paths; directories are contained in their parent, so
+// their name is kept
+// It returns an empty string if the paths have no common root, e.g. on different volumes.
+func commonRoot(paths []string) string {
+	root := ""
+	for i, current := range paths {
+		absolute, err := filepath.Abs(current)
+		if err != nil {
+			return ""
+		}
+
+		parent := filepath.Dir(absolute)
+		if i == 0 {
+			root = parent
+			continue
+		}
+
+		for root != parent && !strings.HasPrefix(parent, strings.TrimSuffix(root, string(filepath.Separator))+
+			string(filepath.Separator)) {
+			next := filepath.Dir(root)
+			if next == root {
+				return ""
+			}
+			root = next
+		}
+	}
+
+	return root
+}
+
+// relativePath returns the slash-separated path of a file or directory relative to the common root of all paths
+func relativePath(base string, current string) string {
+	absolute, err := filepath.Abs(current)
+	if err == nil && base != "" {
+		if relative, err := filepath.Rel(base, absolute); err == nil {
+			return bundlePath(relative)
+		}
+	}
+
+	return bundlePath(current)
+}
+
+// checkDuplicates refuses bundles that contain a path more than once, since extracting them would lose files
+func checkDuplicates(files []BundleFile) error {
+	seen := map[string]bool{}
+	for _, file := range files {
+		if seen[file.Path] {
+			return fmt.Errorf("Duplicate path '%s': several files would be stored under the same name", file.Path)
+		}
+		seen[file.Path] = true
+	}
+
+	return nil
+}
+
+func walkDirectory(root string, prefix string, maxSize int64) ([]BundleFile, []SkippedFile, error) {
+	var files []BundleFile
+	var skipped []SkippedFile
+	rules := &util.IgnoreRules{}
+
+	err := filepath.Walk(root, func(current string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(root, current)
+		if err != nil {
+			return err
+		}
+		relative = filepath.ToSlash(relative)
+		bundled := path.Join(prefix, relative)
+
+		if info.IsDir() {
+			if relative != "." && (info.Name() == ".git" || rules.Match(relative, true)) {
+				return filepath.SkipDir
+			}
+
+			for _, name := range IgnoreFiles {
+				if content, err := ioutil.ReadFile(filepath.Join(current, name)); err == nil {
+					rules.Add(relative, string(content))
+				}
+			}
+			return nil
+		}
+
+		if rules.Match(relative, false) {
+			return nil
+		}
+
+		if !info.Mode().IsRegular() {
+			skipped = append(skipped, SkippedFile{Path: bundled, Reason: "not a regular file"})
+			return nil
+		}
+
+		if maxSize > 0 && info.Size() > maxSize {
+			skipped = append(skipped, SkippedFile{Path: bundled, Reason: "too large"})
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(current)
+		if err != nil {
+			return err
+		}
+
+		if IsBinary(content) {
+			skipped = append(skipped, SkippedFile{Path: bundled, Reason: "binary"})
+			return nil
+		}
+
+		files = append(files, BundleFile{
+			BundleEntry: BundleEntry{Path: bundled, Size: int64(len(content)), SHA256: hashBytes(content)},
+			Content:     content,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error reading directory: %s", err.Error())
+	}
+
+	return files, skipped, nil
+}
+
+// #endregion
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jagoe/haste-client-go/server"
+)
+
+func TestLoadFilesPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a/x", "b/x", "a/sub/y"} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), 0644)
+	}
+
+	tests := []struct {
+		paths    []string
+		expected []string
+	}{
+		{[]string{filepath.Join(dir, "a", "x")}, []string{"x"}},
+		{[]string{filepath.Join(dir, "a", "x"), filepath.Join(dir, "a", "sub", "y")}, []string{"x", "sub/y"}},
+		{[]string{filepath.Join(dir, "a", "x"), filepath.Join(dir, "b", "x")}, []string{"a/x", "b/x"}},
+		{[]string{filepath.Join(dir, "a", "sub"), filepath.Join(dir, "b")}, []string{"a/sub/y", "b/x"}},
+	}
+
+	for _, test := range tests {
+		files, _, err := LoadFiles(test.paths, 0, BinaryEncode)
+		if err != nil {
+			t.Fatalf("%v: Should not have returned error: %s", test.paths, err.Error())
+		}
+
+		var paths []string
+		for _, file := range files {
+			paths = append(paths, file.Path)
+		}
+		if !reflect.DeepEqual(paths, test.expected) {
+			t.Errorf("%v: Expected %v, got %v", test.paths, test.expected, paths)
+		}
+	}
+
+	if _, _, err := LoadFiles([]string{filepath.Join(dir, "a", "x"), filepath.Join(dir, "a", "x")}, 0, BinaryEncode); err == nil {
+		t.Error("Expected duplicate paths to be refused")
+	}
+}
+
+func TestLoadFiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "haste-test")
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		"main.go":          "package main\n",
+		"sub/util.go":      "package sub\n",
+		"app.log":          "ignored\n",
+		"sub/.hasteignore": "secret.txt\n",
+		"sub/secret.txt":   "ignored\n",
+		"image.png":        "\x89PNG\x00",
+		"big.txt":          strings.Repeat("x", 100),
+		".gitignore":       "*.log\n",
+		".git/config":      "ignored\n",
+	} {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+
+	files, skipped, err := LoadFiles([]string{dir}, 50, BinaryEncode)
+	if err != nil {
+		t.Fatalf("Should not have returned error: %s", err.Error())
+	}
+
+	prefix := filepath.Base(dir)
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	sort.Strings(paths)
+
+	expected := []string{prefix + "/.gitignore", prefix + "/main.go", prefix + "/sub/.hasteignore", prefix + "/sub/util.go"}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected files %v, got %v", expected, paths)
+	}
+
+	reasons := map[string]string{}
+	for _, file := range skipped {
+		reasons[file.Path] = file.Reason
+	}
+	if reasons[prefix+"/image.png"] != "binary" || reasons[prefix+"/big.txt"] != "too large" || len(reasons) != 2 {
+		t.Errorf("Unexpected skipped files: %v", reasons)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	fake := NewFakeMemoryServer()
+	files := []BundleFile{
+		{BundleEntry: BundleEntry{Path: "dir/a.txt", Size: 1, SHA256: hashString("a")}, Content: []byte("a")},
+		{BundleEntry: BundleEntry{Path: "dir/b c.txt", Size: 1, SHA256: hashString("b")}, Content: []byte("b")},
+	}
+
+	result, entries, err := CreateIndex(files, fake, "hastebin.local")
+	if err != nil {
+		t.Fatalf("Should not have returned error: %s", err.Error())
+	}
+
+	if len(entries) != 2 || !strings.HasPrefix(entries[0].URL, "hastebin.local/") {
+		t.Fatalf("Unexpected index entries: %v", entries)
+	}
+
+	index := fake.hastes[result.Key]
+	parsed, err := ParseIndex([]byte(index))
+	if err != nil {
+		t.Fatalf("Should not have returned error: %s", err.Error())
+	}
+
+	fetched, err := FetchIndex(parsed, func(url string) (server.HasteGetter, string, error) {
+		return fake, strings.TrimPrefix(url, "hastebin.local/"), nil
+	})
+	if err != nil {
+		t.Fatalf("Should not have returned error: %s", err.Error())
+	}
+
+	for i, file := range files {
+		if fetched[i].Path != file.Path || !bytes.Equal(fetched[i].Content, file.Content) {
+			t.Errorf("Expected %s to round-trip, got %s: %q", file.Path, fetched[i].Path, fetched[i].Content)
+		}
+	}
+
+	_, err = FetchIndex(parsed, func(url string) (server.HasteGetter, string, error) {
+		return nil, "", fmt.Errorf("refused")
+	})
+	if err == nil || !strings.Contains(err.Error(), "refused") {
+		t.Errorf("Expected the error of getterFor to be returned, got %v", err)
+	}
+}
+
+func TestPrintTree(t *testing.T) {
+	buffer := bytes.NewBufferString("")
+	PrintTree(buffer, []string{"dir/sub/b.go", "dir/a.go", "dir/sub/c.go", "other.txt"}, map[string]string{"dir/a.go": "url"})
+
+	expected := "dir/\n  a.go  url\n  sub/\n    b.go\n    c.go\nother.txt\n"
+	if buffer.String() != expected {
+		t.Errorf("Expected tree %q, got %q", expected, buffer.String())
+	}
+}
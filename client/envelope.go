@@ -0,0 +1,239 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// BinaryPolicy determines how binary input is handled when creating a haste
+type BinaryPolicy string
+
+const (
+	// BinaryRefuse refuses to upload binary input
+	BinaryRefuse BinaryPolicy = "refuse"
+	// BinaryEncode wraps binary input in a base64 envelope
+	BinaryEncode BinaryPolicy = "encode"
+)
+
+const (
+	envelopeHeader    = "-----BEGIN HASTE ENVELOPE-----"
+	envelopeFooter    = "-----END HASTE ENVELOPE-----"
+	envelopeLineWidth = 76
+	sniffLength       = 8000
+)
+
+// Envelope describes binary content that has been base64 encoded to be stored on a haste-server
+type Envelope struct {
+	Filename string `json:"filename,omitempty"`
+	MIME     string `json:"mime"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+}
+
+// ParseBinaryPolicy validates a binary policy provided by the user
+func ParseBinaryPolicy(policy string) (BinaryPolicy, error) {
+	switch BinaryPolicy(policy) {
+	case BinaryRefuse, BinaryEncode:
+		return BinaryPolicy(policy), nil
+	default:
+		return "", fmt.Errorf("Invalid binary policy '%s': expected '%s' or '%s'", policy, BinaryRefuse, BinaryEncode)
+	}
+}
+
+// IsBinary reports whether content looks like binary data rather than text
+func IsBinary(content []byte) bool {
+	if bytes.IndexByte(content, 0) >= 0 {
+		return true
+	}
+
+	// the sniffed content may end in the middle of a multi-byte character
+	for i := 0; i < utf8.UTFMax && len(content) > 0 && !utf8.Valid(content); i++ {
+		content = content[:len(content)-1]
+	}
+
+	return !utf8.Valid(content)
+}
+
+// DetectBinary inspects the beginning of the input and wraps it in an envelope if it is binary
+// The returned reader must be used instead of the original input.
+func DetectBinary(input io.Reader, filename string, policy BinaryPolicy) (io.Reader, error) {
+	buffered := bufio.NewReaderSize(input, sniffLength)
+	head, err := buffered.Peek(sniffLength)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, fmt.Errorf("Error reading input: %s", err.Error())
+	}
+
+	if !IsBinary(head) {
+		return buffered, nil
+	}
+
+	if policy != BinaryEncode {
+		return nil, fmt.Errorf("Input is binary and cannot be stored as text; use --binary=encode to upload it anyway")
+	}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(filename))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(head)
+	}
+
+	return EncodeEnvelope(buffered, Envelope{Filename: filepath.Base(filename), MIME: mimeType}), nil
+}
+
+// EncodeEnvelope streams the input as a base64 envelope carrying the provided filename and MIME type
+// Size and checksum are calculated while encoding. The input is only read while the envelope is read, so an envelope
+// that is never read does not hold on to any resources.
+func EncodeEnvelope(input io.Reader, envelope Envelope) io.Reader {
+	if envelope.Filename == "." || envelope.Filename == string(filepath.Separator) {
+		envelope.Filename = ""
+	}
+
+	encoder := &envelopeEncoder{input: input, hash: sha256.New()}
+	header, _ := json.Marshal(Envelope{Filename: envelope.Filename, MIME: envelope.MIME})
+	fmt.Fprintf(&encoder.pending, "%s\n%s\n", envelopeHeader, header)
+	encoder.lines = &lineWrapper{out: &encoder.pending, width: envelopeLineWidth}
+	encoder.base64 = base64.NewEncoder(base64.StdEncoding, encoder.lines)
+
+	return encoder
+}
+
+// ParseEnvelope decodes a haste that contains a base64 envelope or returns false if the haste is not an envelope
+func ParseEnvelope(haste string) (*Envelope, []byte, bool, error) {
+	if !strings.HasPrefix(haste, envelopeHeader+"\n") {
+		return nil, nil, false, nil
+	}
+
+	lines := strings.Split(strings.TrimRight(haste, "\r\n"), "\n")
+	if len(lines) < 4 || strings.TrimSpace(lines[len(lines)-1]) != envelopeFooter {
+		return nil, nil, true, fmt.Errorf("Error decoding envelope: incomplete envelope")
+	}
+
+	var envelope Envelope
+	if err := json.Unmarshal([]byte(lines[1]), &envelope); err != nil {
+		return nil, nil, true, fmt.Errorf("Error decoding envelope: %s", err.Error())
+	}
+
+	var trailer Envelope
+	if err := json.Unmarshal([]byte(lines[len(lines)-2]), &trailer); err != nil {
+		return nil, nil, true, fmt.Errorf("Error decoding envelope: %s", err.Error())
+	}
+	envelope.Size, envelope.SHA256 = trailer.Size, trailer.SHA256
+
+	content, err := base64.StdEncoding.DecodeString(strings.Join(lines[2:len(lines)-2], ""))
+	if err != nil {
+		return nil, nil, true, fmt.Errorf("Error decoding envelope: %s", err.Error())
+	}
+
+	if int64(len(content)) != envelope.Size || hashBytes(content) != envelope.SHA256 {
+		return nil, nil, true, fmt.Errorf("Error decoding envelope: checksum mismatch")
+	}
+
+	return &envelope, content, true, nil
+}
+
+// #region Private
+
+// envelopeEncoder encodes its input a block at a time whenever the encoded data has been read completely
+type envelopeEncoder struct {
+	input   io.Reader
+	hash    hash.Hash
+	size    int64
+	base64  io.WriteCloser
+	lines   *lineWrapper
+	pending bytes.Buffer
+	block   []byte
+	done    bool
+	err     error
+}
+
+func (encoder *envelopeEncoder) Read(p []byte) (int, error) {
+	for encoder.pending.Len() == 0 {
+		if encoder.err != nil {
+			return 0, encoder.err
+		}
+		if encoder.done {
+			return 0, io.EOF
+		}
+		encoder.fill()
+	}
+
+	return encoder.pending.Read(p)
+}
+
+// fill encodes the next block of the input or the trailer at the end of the input
+func (encoder *envelopeEncoder) fill() {
+	if encoder.block == nil {
+		encoder.block = make([]byte, 32*1024)
+	}
+
+	n, err := encoder.input.Read(encoder.block)
+	encoder.hash.Write(encoder.block[:n])
+	encoder.base64.Write(encoder.block[:n])
+	encoder.size += int64(n)
+
+	if err == io.EOF {
+		encoder.base64.Close()
+		encoder.lines.finish()
+
+		trailer, _ := json.Marshal(struct {
+			Size   int64  `json:"size"`
+			SHA256 string `json:"sha256"`
+		}{encoder.size, hex.EncodeToString(encoder.hash.Sum(nil))})
+		fmt.Fprintf(&encoder.pending, "%s\n%s\n", trailer, envelopeFooter)
+		encoder.done = true
+	} else if err != nil {
+		encoder.err = fmt.Errorf("Error reading input: %s", err.Error())
+	}
+}
+
+// lineWrapper inserts a line break after every width bytes
+type lineWrapper struct {
+	out     io.Writer
+	width   int
+	written int
+}
+
+func (wrapper *lineWrapper) Write(data []byte) (int, error) {
+	total := len(data)
+	for len(data) > 0 {
+		n := wrapper.width - wrapper.written
+		if n > len(data) {
+			n = len(data)
+		}
+
+		if _, err := wrapper.out.Write(data[:n]); err != nil {
+			return 0, err
+		}
+		data = data[n:]
+		wrapper.written += n
+
+		if wrapper.written == wrapper.width {
+			if _, err := wrapper.out.Write([]byte{'\n'}); err != nil {
+				return 0, err
+			}
+			wrapper.written = 0
+		}
+	}
+
+	return total, nil
+}
+
+func (wrapper *lineWrapper) finish() {
+	if wrapper.written > 0 {
+		wrapper.out.Write([]byte{'\n'})
+		wrapper.written = 0
+	}
+}
+
+// #endregion
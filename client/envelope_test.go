@@ -0,0 +1,79 @@
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+)
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		title   string
+		content []byte
+		binary  bool
+	}{
+		{"Empty", []byte{}, false},
+		{"Text", []byte("Just text\n"), false},
+		{"Truncated multi-byte character", []byte("🙃")[:3], false},
+		{"NUL byte", []byte("a\x00b"), true},
+		{"Invalid UTF-8", []byte{0xff, 0xfe, 'a', 'b', 'c', 'd'}, true},
+	}
+
+	for _, test := range tests {
+		if IsBinary(test.content) != test.binary {
+			t.Errorf("%s: Expected IsBinary to be %t", test.title, test.binary)
+		}
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	t.Run("should round-trip content", func(t *testing.T) {
+		binary := bytes.Repeat([]byte{0, 1, 2, 0xff}, 100)
+		encoded, _ := ioutil.ReadAll(EncodeEnvelope(bytes.NewBuffer(binary), Envelope{Filename: "file.bin", MIME: "application/octet-stream"}))
+
+		envelope, content, ok, err := ParseEnvelope(string(encoded))
+		if !ok || err != nil {
+			t.Fatalf("Expected a valid envelope, got %v", err)
+		}
+
+		if !bytes.Equal(content, binary) {
+			t.Errorf("Expected decoded content to equal the original content")
+		}
+
+		if envelope.Filename != "file.bin" || envelope.Size != int64(len(binary)) {
+			t.Errorf("Unexpected envelope metadata: %+v", envelope)
+		}
+	})
+
+	t.Run("should only read the input while the envelope is read", func(t *testing.T) {
+		read := 0
+		input := &countingReader{reader: iotest.OneByteReader(bytes.NewReader(bytes.Repeat([]byte{0, 1}, 5000))), read: &read}
+		envelope := EncodeEnvelope(input, Envelope{})
+		if read != 0 {
+			t.Fatalf("Expected no reads before the envelope is read, got %d bytes", read)
+		}
+
+		encoded, _ := ioutil.ReadAll(envelope)
+		if _, content, ok, err := ParseEnvelope(string(encoded)); !ok || err != nil || len(content) != 10000 {
+			t.Errorf("Expected a valid envelope of 10000 bytes, got %d bytes and %v", len(content), err)
+		}
+	})
+
+	t.Run("should ignore text hastes", func(t *testing.T) {
+		_, _, ok, err := ParseEnvelope("just text")
+		if ok || err != nil {
+			t.Errorf("Expected text not to be treated as an envelope")
+		}
+	})
+
+	t.Run("should detect corrupted envelopes", func(t *testing.T) {
+		encoded, _ := ioutil.ReadAll(EncodeEnvelope(bytes.NewBufferString("\x00content"), Envelope{}))
+		corrupted := bytes.Replace(encoded, []byte("AGNvbn"), []byte("AGNvbm"), 1)
+
+		_, _, ok, err := ParseEnvelope(string(corrupted))
+		if !ok || err == nil {
+			t.Errorf("Expected corrupted envelope to return an error")
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/template"
+)
+
+// OutputFormat determines how created and retrieved hastes are described
+type OutputFormat string
+
+const (
+	// OutputText prints the URL of created hastes and nothing for retrieved hastes
+	OutputText OutputFormat = "text"
+	// OutputJSON prints a JSON object
+	OutputJSON OutputFormat = "json"
+)
+
+// DocumentInfo describes a haste that has been retrieved from the server
+type DocumentInfo struct {
+	Key      string `json:"key"`
+	URL      string `json:"url"`
+	RawURL   string `json:"rawUrl"`
+	Server   string `json:"server"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+	Filename string `json:"filename,omitempty"`
+	MIME     string `json:"mime,omitempty"`
+	// Path is the file the haste has been saved to
+	Path string `json:"path,omitempty"`
+}
+
+// Printer describes hastes as text, JSON or with a Go template
+type Printer struct {
+	Format   OutputFormat
+	Template *template.Template
+	// Newline ends the output with a line break if it does not end with one already
+	Newline bool
+}
+
+// NewPrinter creates a printer for an output format or a Go template, which takes precedence
+func NewPrinter(format string, tmpl string, newline bool) (*Printer, error) {
+	printer := &Printer{Format: OutputFormat(format), Newline: newline}
+	switch printer.Format {
+	case OutputText, OutputJSON:
+	case "":
+		printer.Format = OutputText
+	default:
+		return nil, fmt.Errorf("Invalid output format '%s': expected text|json", format)
+	}
+
+	if tmpl != "" {
+		parsed, err := template.New("format").Parse(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid format template: %s", err.Error())
+		}
+		printer.Template = parsed
+	}
+
+	return printer, nil
+}
+
+// MakeDocumentInfo describes a retrieved haste
+func MakeDocumentInfo(document *Document, serverURL string, path string) DocumentInfo {
+	info := DocumentInfo{
+		Key:    document.Key,
+		URL:    fmt.Sprintf("%s/%s", serverURL, document.Key),
+		RawURL: fmt.Sprintf("%s/raw/%s", serverURL, document.Key),
+		Server: serverURL,
+		Size:   int64(len(document.Content)),
+		SHA256: hashBytes(document.Content),
+		Path:   path,
+	}
+
+	if document.Envelope != nil {
+		info.Filename = document.Envelope.Filename
+		info.MIME = document.Envelope.MIME
+	}
+
+	return info
+}
+
+// Print describes a haste; text is used for OutputText and may be empty to print nothing
+func (printer *Printer) Print(out io.Writer, value interface{}, text string) error {
+	var output bytes.Buffer
+	switch {
+	case printer.Template != nil:
+		if err := printer.Template.Execute(&output, value); err != nil {
+			return fmt.Errorf("Error formatting output: %s", err.Error())
+		}
+	case printer.Format == OutputJSON:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("Error formatting output: %s", err.Error())
+		}
+		output.Write(encoded)
+	default:
+		output.WriteString(text)
+	}
+
+	if printer.Newline && output.Len() > 0 && !bytes.HasSuffix(output.Bytes(), []byte("\n")) {
+		output.WriteByte('\n')
+	}
+
+	_, err := out.Write(output.Bytes())
+	return err
+}
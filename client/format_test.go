@@ -0,0 +1,56 @@
+package client
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrinter(t *testing.T) {
+	result := &Result{Key: "abc", URL: "https://hastebin.com/abc", RawURL: "https://hastebin.com/raw/abc",
+		Server: "https://hastebin.com", Size: 4, SHA256: "hash", ViewURL: "https://hastebin.com/abc.go", Extension: "go"}
+
+	tests := []struct {
+		title    string
+		format   string
+		template string
+		newline  bool
+		expected string
+	}{
+		{"Text", "", "", false, "https://hastebin.com/abc"},
+		{"Text on a terminal", "text", "", true, "https://hastebin.com/abc\n"},
+		{"JSON", "json", "", false, `{"key":"abc","url":"https://hastebin.com/abc","rawUrl":"https://hastebin.com/raw/abc",` +
+			`"server":"https://hastebin.com","size":4,"sha256":"hash","viewUrl":"https://hastebin.com/abc.go","extension":"go"}`},
+		{"Template", "json", "{{.RawURL}} ({{.Size}} bytes)", false, "https://hastebin.com/raw/abc (4 bytes)"},
+	}
+
+	for _, test := range tests {
+		printer, err := NewPrinter(test.format, test.template, test.newline)
+		if err != nil {
+			t.Fatalf("%s: Expected NewPrinter not to return an error, got %s", test.title, err.Error())
+		}
+
+		buffer := bytes.NewBufferString("")
+		printer.Print(buffer, result, result.URL)
+		if buffer.String() != test.expected {
+			t.Errorf("%s: Expected '%s', got '%s'", test.title, test.expected, buffer.String())
+		}
+	}
+
+	t.Run("should reject invalid formats", func(t *testing.T) {
+		if _, err := NewPrinter("yaml", "", false); err == nil {
+			t.Error("Expected an unknown format to be rejected")
+		}
+		if _, err := NewPrinter("", "{{.Key", false); err == nil {
+			t.Error("Expected an invalid template to be rejected")
+		}
+	})
+
+	t.Run("should print nothing for empty text", func(t *testing.T) {
+		printer, _ := NewPrinter("text", "", true)
+		buffer := bytes.NewBufferString("")
+		printer.Print(buffer, MakeDocumentInfo(&Document{Key: "abc"}, "https://hastebin.com", ""), "")
+		if buffer.Len() != 0 {
+			t.Errorf("Expected no output, got '%s'", buffer.String())
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DetectLength is the number of bytes DetectExtension needs to detect the language of content
+const DetectLength = 4096
+
+// languages maps language names to the extensions haste-server highlights them by
+var languages = map[string]string{
+	"bash": "sh", "c": "c", "c++": "cpp", "cpp": "cpp", "csharp": "cs", "c#": "cs", "css": "css", "diff": "diff",
+	"dockerfile": "dockerfile", "go": "go", "golang": "go", "html": "html", "ini": "ini", "java": "java",
+	"javascript": "js", "js": "js", "json": "json", "kotlin": "kt", "lua": "lua", "makefile": "makefile",
+	"markdown": "md", "perl": "pl", "php": "php", "powershell": "ps1", "python": "py", "ruby": "rb", "rust": "rs",
+	"scala": "scala", "shell": "sh", "sql": "sql", "swift": "swift", "toml": "toml", "typescript": "ts", "xml": "xml",
+	"yaml": "yaml",
+}
+
+// filenames maps files without a meaningful extension to the extension of their language
+var filenames = map[string]string{"dockerfile": "dockerfile", "makefile": "makefile", "gemfile": "rb", "rakefile": "rb"}
+
+// signatures detect the language of content without a filename; the first match wins
+var signatures = []struct {
+	extension string
+	pattern   *regexp.Regexp
+}{
+	{"sh", regexp.MustCompile(`\A#!\s*/(usr/)?bin/(env\s+)?(ba|z|k)?sh\b`)},
+	{"py", regexp.MustCompile(`\A#!\s*/(usr/)?bin/(env\s+)?python`)},
+	{"rb", regexp.MustCompile(`\A#!\s*/(usr/)?bin/(env\s+)?ruby`)},
+	{"js", regexp.MustCompile(`\A#!\s*/(usr/)?bin/(env\s+)?node`)},
+	{"php", regexp.MustCompile(`\A<\?php`)},
+	{"diff", regexp.MustCompile(`(?m)\A(diff --git |--- \S+.*\n\+\+\+ )`)},
+	{"html", regexp.MustCompile(`(?i)\A\s*(<!doctype html|<html)`)},
+	{"xml", regexp.MustCompile(`\A\s*<\?xml`)},
+	{"json", regexp.MustCompile(`\A\s*[{\[]\s*("|\]|\}|\{|\[|-?\d|true|false|null)`)},
+	{"go", regexp.MustCompile(`(?m)^package \w+\s*$[\s\S]*^(import|func|type|var|const)\b`)},
+	{"rs", regexp.MustCompile(`(?m)^\s*(pub )?fn \w+.*\{|^use \w+(::\w+)+;`)},
+	{"java", regexp.MustCompile(`(?m)^\s*(public )?(final )?class \w+.*\{|^import java\.`)},
+	{"c", regexp.MustCompile(`(?m)^#include\s*[<"]`)},
+	{"py", regexp.MustCompile(`(?m)^(def \w+\(.*\):|class \w+(\(.*\))?:|from \w+(\.\w+)* import |import \w+\s*$)`)},
+	{"js", regexp.MustCompile(`(?m)^(const|let) \w+ = require\(|^import .* from ['"]|^export (default |const |function )`)},
+	{"sql", regexp.MustCompile(`(?i)\A\s*(select .* from |insert into |update \w+ set |create (table|index|view) )`)},
+	{"dockerfile", regexp.MustCompile(`(?i)\A(#.*\n)*FROM \S+`)},
+	{"yaml", regexp.MustCompile(`(?m)\A(---\s*\n|\w[\w-]*:( .*)?\n(\s+\S.*\n|\w[\w-]*:( .*)?\n)+)`)},
+	{"md", regexp.MustCompile(`(?m)\A# .+\n`)},
+}
+
+// LanguageExtension returns the extension haste-server highlights a language by
+func LanguageExtension(language string) (string, error) {
+	extension, ok := languages[strings.ToLower(language)]
+	if !ok {
+		return "", fmt.Errorf("Unknown language '%s'", language)
+	}
+
+	return extension, nil
+}
+
+// DetectExtension determines the extension that makes haste-server highlight the content
+// The extension is taken from the filename if possible and otherwise detected from the first DetectLength bytes of the
+// content. Binary envelopes are not highlighted.
+func DetectExtension(filename string, head []byte) string {
+	if bytes.HasPrefix(head, []byte(envelopeHeader)) {
+		return ""
+	}
+
+	if filename != "" {
+		base := strings.ToLower(filepath.Base(filename))
+		if extension, ok := filenames[base]; ok {
+			return extension
+		}
+
+		if extension := strings.TrimPrefix(filepath.Ext(base), "."); extension != "" {
+			return extension
+		}
+	}
+
+	for _, signature := range signatures {
+		if signature.pattern.Match(head) {
+			return signature.extension
+		}
+	}
+
+	return ""
+}
+
+// WithExtension sets the extension of the view URL of a created haste
+func (result *Result) WithExtension(extension string) *Result {
+	result.Extension = strings.TrimPrefix(extension, ".")
+	result.ViewURL = result.URL
+	if result.Extension != "" {
+		result.ViewURL = fmt.Sprintf("%s.%s", result.URL, result.Extension)
+	}
+
+	return result
+}
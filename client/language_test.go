@@ -0,0 +1,47 @@
+package client
+
+import "testing"
+
+func TestDetectExtension(t *testing.T) {
+	tests := []struct {
+		title    string
+		filename string
+		head     string
+		expected string
+	}{
+		{"Filename", "./cmd/main.go", "", "go"},
+		{"Filename wins over content", "notes.txt", "package main\n\nfunc main() {}", "txt"},
+		{"Known filename", "build/Dockerfile", "", "dockerfile"},
+		{"Binary envelope", "image.png", envelopeHeader + "\n{}", ""},
+		{"Shebang", "", "#!/usr/bin/env bash\necho hi", "sh"},
+		{"Go", "", "// comment\npackage main\n\nimport \"fmt\"\n", "go"},
+		{"JSON", "", `  {"key": "value"}`, "json"},
+		{"Python", "", "import os\n\ndef main():\n    pass\n", "py"},
+		{"Diff", "", "diff --git a/x b/x\n", "diff"},
+		{"YAML", "", "server: https://hastebin.com\nclientCert: ./cert.pem\n", "yaml"},
+		{"Plain text", "", "This is a test.\nNothing to see here.", ""},
+	}
+
+	for _, test := range tests {
+		if extension := DetectExtension(test.filename, []byte(test.head)); extension != test.expected {
+			t.Errorf("%s: Expected '%s', got '%s'", test.title, test.expected, extension)
+		}
+	}
+}
+
+func TestLanguageExtension(t *testing.T) {
+	if extension, err := LanguageExtension("Python"); err != nil || extension != "py" {
+		t.Errorf("Expected 'py', got '%s', %v", extension, err)
+	}
+
+	if _, err := LanguageExtension("klingon"); err == nil {
+		t.Error("Expected an unknown language to be rejected")
+	}
+}
+
+func TestWithExtension(t *testing.T) {
+	result := (&Result{URL: "https://hastebin.com/abc"}).WithExtension(".go")
+	if result.ViewURL != "https://hastebin.com/abc.go" || result.Extension != "go" {
+		t.Errorf("Expected the view URL to end with .go, got %+v", result)
+	}
+}
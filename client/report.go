@@ -0,0 +1,174 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Report describes a command that has been run, so it can be shared in a bug report
+type Report struct {
+	Command  []string
+	Dir      string
+	Started  time.Time
+	Duration time.Duration
+	// Status describes how the command ended, e.g. "exit status 1"
+	Status   string
+	ExitCode int
+	Env      []string
+	// Output contains STDOUT and STDERR of the command, interleaved as they have been written
+	Output []byte
+}
+
+// OutputBuffer captures the output of a command up to Limit bytes; with a limit of 0, only the marker is kept
+// Beyond the limit, the beginning and the end of the output are kept and the lines in between are replaced by a marker,
+// since the end usually explains why a command failed.
+type OutputBuffer struct {
+	Limit int
+
+	head    []byte
+	tail    []byte
+	omitted int64
+}
+
+var unquotedPattern = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
+
+// Bytes formats the report as a header that describes the command followed by its output
+func (report Report) Bytes() []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "$ %s\n", QuoteCommand(report.Command))
+	fmt.Fprintf(&out, "Status:    %s\n", report.Status)
+	fmt.Fprintf(&out, "Duration:  %s\n", report.Duration.Round(time.Millisecond))
+	fmt.Fprintf(&out, "Started:   %s\n", report.Started.Format(time.RFC3339))
+	fmt.Fprintf(&out, "Directory: %s\n", report.Dir)
+	fmt.Fprintf(&out, "Platform:  %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	if len(report.Env) > 0 {
+		fmt.Fprintln(&out, "Environment:")
+		for _, variable := range report.Env {
+			fmt.Fprintf(&out, "  %s\n", variable)
+		}
+	}
+
+	fmt.Fprintf(&out, "%s\n", strings.Repeat("-", 80))
+	out.Write(report.Output)
+	if len(report.Output) > 0 && !bytes.HasSuffix(report.Output, []byte("\n")) {
+		out.WriteString("\n")
+	}
+
+	return out.Bytes()
+}
+
+func (buffer *OutputBuffer) Write(p []byte) (int, error) {
+	written := len(p)
+	if free := buffer.headLimit() - len(buffer.head); free > 0 {
+		if free > len(p) {
+			free = len(p)
+		}
+		buffer.head = append(buffer.head, p[:free]...)
+		p = p[free:]
+	}
+
+	buffer.tail = append(buffer.tail, p...)
+	if tailLimit := buffer.tailLimit(); len(buffer.tail) > 2*tailLimit {
+		// the tail is only shifted once it has grown to twice its size, so every byte is copied only a few times
+		drop := len(buffer.tail) - tailLimit
+		buffer.omitted += int64(drop)
+		buffer.tail = append([]byte{}, buffer.tail[drop:]...)
+	}
+
+	return written, nil
+}
+
+// Bytes returns the captured output, with a marker in place of the omitted lines if the output exceeded the limit
+func (buffer *OutputBuffer) Bytes() []byte {
+	head, tail, omitted := buffer.head, buffer.tail, buffer.omitted
+	if tailLimit := buffer.tailLimit(); len(tail) > tailLimit {
+		omitted += int64(len(tail) - tailLimit)
+		tail = tail[len(tail)-tailLimit:]
+	}
+
+	if omitted == 0 {
+		return append(append([]byte{}, head...), tail...)
+	}
+
+	// only complete lines are kept around the marker
+	if index := bytes.LastIndexByte(head, '\n'); index >= 0 {
+		omitted += int64(len(head) - index - 1)
+		head = head[:index+1]
+	}
+	if index := bytes.IndexByte(tail, '\n'); index >= 0 {
+		omitted += int64(index + 1)
+		tail = tail[index+1:]
+	}
+
+	var out bytes.Buffer
+	out.Write(head)
+	if len(head) > 0 && !bytes.HasSuffix(head, []byte("\n")) {
+		out.WriteString("\n")
+	}
+	fmt.Fprintf(&out, "[... %d bytes omitted ...]\n", omitted)
+	out.Write(tail)
+
+	return out.Bytes()
+}
+
+// QuoteCommand joins a command and its arguments so it can be pasted into a POSIX shell
+func QuoteCommand(command []string) string {
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		if unquotedPattern.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// FilterEnv returns the environment variables whose names match one of the glob patterns, e.g. GO* or PATH
+// Variables are only included if they are explicitly allowed, since the environment often contains credentials.
+func FilterEnv(environ []string, patterns []string) ([]string, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("Invalid environment pattern '%s': %s", pattern, err.Error())
+		}
+	}
+
+	var allowed []string
+	for _, variable := range environ {
+		name := strings.SplitN(variable, "=", 2)[0]
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, name); matched {
+				allowed = append(allowed, variable)
+				break
+			}
+		}
+	}
+
+	return allowed, nil
+}
+
+// #region Private
+
+func (buffer *OutputBuffer) headLimit() int {
+	if buffer.Limit <= 0 {
+		return 0
+	}
+
+	return buffer.Limit / 2
+}
+
+func (buffer *OutputBuffer) tailLimit() int {
+	if buffer.Limit <= 0 {
+		return 0
+	}
+
+	return buffer.Limit - buffer.headLimit()
+}
+
+// #endregion
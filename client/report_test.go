@@ -0,0 +1,106 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	t.Run("should describe the command before its output", func(t *testing.T) {
+		report := Report{
+			Command:  []string{"go", "test", "-run", "Test Report"},
+			Dir:      "/src",
+			Started:  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			Duration: 1500 * time.Millisecond,
+			Status:   "exit status 1",
+			ExitCode: 1,
+			Env:      []string{"GOFLAGS=-mod=mod"},
+			Output:   []byte("FAIL"),
+		}
+
+		text := string(report.Bytes())
+		for _, expected := range []string{
+			"$ go test -run 'Test Report'\n",
+			"Status:    exit status 1\n",
+			"Duration:  1.5s\n",
+			"Started:   2020-01-02T03:04:05Z\n",
+			"Directory: /src\n",
+			"Environment:\n  GOFLAGS=-mod=mod\n",
+		} {
+			if !strings.Contains(text, expected) {
+				t.Errorf(`Expected report to contain "%s", got "%s"`, expected, text)
+			}
+		}
+
+		if !strings.HasSuffix(text, "-\nFAIL\n") {
+			t.Errorf(`Expected the output to follow the header, got "%s"`, text)
+		}
+	})
+}
+
+func TestOutputBuffer(t *testing.T) {
+	t.Run("should keep output within the limit", func(t *testing.T) {
+		buffer := &OutputBuffer{Limit: 100}
+		fmt.Fprint(buffer, "first\n")
+		fmt.Fprint(buffer, "second\n")
+
+		if string(buffer.Bytes()) != "first\nsecond\n" {
+			t.Errorf(`Expected the complete output, got "%s"`, buffer.Bytes())
+		}
+	})
+
+	t.Run("should omit the middle of output beyond the limit", func(t *testing.T) {
+		buffer := &OutputBuffer{Limit: 60}
+		total := 0
+		for i := 1; i <= 1000; i++ {
+			n, _ := fmt.Fprintf(buffer, "line %d\n", i)
+			total += n
+		}
+
+		output := string(buffer.Bytes())
+		if !strings.HasPrefix(output, "line 1\nline 2\n") || !strings.HasSuffix(output, "line 999\nline 1000\n") {
+			t.Errorf(`Expected the beginning and the end of the output, got "%s"`, output)
+		}
+
+		lines := strings.Split(output, "\n")
+		var kept int
+		for _, line := range lines {
+			if strings.HasPrefix(line, "line ") {
+				kept += len(line) + 1
+			}
+		}
+		if !strings.Contains(output, fmt.Sprintf("\n[... %d bytes omitted ...]\n", total-kept)) || kept > 60 {
+			t.Errorf(`Expected a marker for the omitted lines, got "%s"`, output)
+		}
+	})
+}
+
+func TestQuoteCommand(t *testing.T) {
+	quoted := QuoteCommand([]string{"echo", "it's", "a b", "--flag=value", ""})
+	if quoted != `echo 'it'\''s' 'a b' --flag=value ''` {
+		t.Errorf(`Unexpected quoted command: %s`, quoted)
+	}
+}
+
+func TestFilterEnv(t *testing.T) {
+	t.Run("should only return allowed variables", func(t *testing.T) {
+		environ := []string{"GOPATH=/go", "GOFLAGS=-v", "AWS_SECRET_ACCESS_KEY=secret", "PATH=/bin"}
+
+		allowed, err := FilterEnv(environ, []string{"GO*", "PATH"})
+		if err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		if strings.Join(allowed, " ") != "GOPATH=/go GOFLAGS=-v PATH=/bin" {
+			t.Errorf("Unexpected variables: %v", allowed)
+		}
+	})
+
+	t.Run("should return an error for invalid patterns", func(t *testing.T) {
+		if _, err := FilterEnv(nil, []string{"GO["}); err == nil {
+			t.Errorf("Should have returned an error")
+		}
+	})
+}
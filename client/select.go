@@ -0,0 +1,422 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jagoe/haste-client-go/server"
+)
+
+// Selection determines which lines of an input are uploaded
+// Lines are selected by range first, then by pattern and finally from the start or the end. Dropped lines are replaced
+// by a marker that notes how many lines have been omitted.
+type Selection struct {
+	// From and To are the first and last line of a range, starting at 1; 0 means unbounded
+	From, To int
+	// Grep selects lines that match the pattern together with Context lines before and after them
+	Grep    *regexp.Regexp
+	Context int
+	// Head selects the first lines
+	Head int
+	// Tail selects the last lines
+	Tail int
+	// Quiet drops lines without a marker and stops reading as soon as no further lines can be selected
+	Quiet bool
+}
+
+var (
+	rangePattern = regexp.MustCompile(`^(.+):(\d+)(?:-(\d*))?$`)
+	linesPattern = regexp.MustCompile(`^L?(\d*)(?:([:-])L?(\d*))?$`)
+)
+
+// IsEmpty reports whether the selection selects every line
+func (selection Selection) IsEmpty() bool {
+	return selection.From == 0 && selection.To == 0 && selection.Grep == nil && selection.Head == 0 &&
+		selection.Tail == 0
+}
+
+// ParseLineRange splits a file argument with a line range (main.go:40-90, main.go:40- or main.go:40) into the path and
+// the range
+// Existing files are never split, so files with such names can still be uploaded.
+func ParseLineRange(arg string) (string, int, int, error) {
+	match := rangePattern.FindStringSubmatch(arg)
+	if match == nil {
+		return arg, 0, 0, nil
+	}
+	if _, err := os.Stat(arg); err == nil {
+		return arg, 0, 0, nil
+	}
+
+	from, _ := strconv.Atoi(match[2])
+	to := from
+	if match[3] != "" {
+		to, _ = strconv.Atoi(match[3])
+	} else if len(match[0]) > len(match[1])+len(match[2])+1 {
+		// open range, e.g. main.go:40-
+		to = 0
+	}
+
+	if from < 1 || (to != 0 && to < from) {
+		return "", 0, 0, fmt.Errorf("Invalid line range in '%s'", arg)
+	}
+
+	return match[1], from, to, nil
+}
+
+// ParseLines parses a line range like 10:40, 10-40 or L10-L40 as used in #L anchors; either end may be omitted
+func ParseLines(spec string) (int, int, error) {
+	match := linesPattern.FindStringSubmatch(spec)
+	if match == nil || spec == "" || (match[1] == "" && match[3] == "") {
+		return 0, 0, fmt.Errorf("Invalid line range '%s': expected e.g. 10:40, 10- or L10-L40", spec)
+	}
+
+	from, to := 1, 0
+	if match[1] != "" {
+		from, _ = strconv.Atoi(match[1])
+	}
+	if match[2] == "" {
+		to = from
+	} else if match[3] != "" {
+		to, _ = strconv.Atoi(match[3])
+	}
+
+	if from < 1 || (to != 0 && to < from) {
+		return 0, 0, fmt.Errorf("Invalid line range '%s'", spec)
+	}
+
+	return from, to, nil
+}
+
+// SelectLines streams the selected lines of a text input
+// Closing the returned reader stops reading the input.
+func SelectLines(input io.Reader, selection Selection) (io.ReadCloser, error) {
+	if selection.Head > 0 && selection.Tail > 0 {
+		return nil, fmt.Errorf("Only one of --head and --tail can be used")
+	}
+	if selection.IsEmpty() {
+		return ioutil.NopCloser(input), nil
+	}
+
+	buffered := bufio.NewReader(input)
+	if head, _ := buffered.Peek(len(envelopeHeader)); bytes.Equal(head, []byte(envelopeHeader)) {
+		return nil, fmt.Errorf("Lines cannot be selected from binary input")
+	}
+
+	var stage lineStage
+	reader, writer := io.Pipe()
+	output := &outputStage{out: bufio.NewWriter(writer), complete: true, quiet: selection.Quiet}
+	stage = output
+	if selection.Tail > 0 {
+		stage = &tailStage{count: selection.Tail, next: stage}
+	}
+	if selection.Head > 0 {
+		stage = &headStage{count: selection.Head, next: stage}
+	}
+	if selection.Grep != nil {
+		stage = &grepStage{pattern: selection.Grep, context: selection.Context, next: stage}
+	}
+	if selection.From > 0 || selection.To > 0 {
+		stage = &rangeStage{from: selection.From, to: selection.To, next: stage}
+	}
+
+	go func() {
+		for !selection.Quiet || !stage.full() {
+			line, err := buffered.ReadString('\n')
+			if line != "" {
+				stage.line(line)
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				writer.CloseWithError(fmt.Errorf("Error reading input: %s", err.Error()))
+				return
+			}
+		}
+
+		stage.end()
+		writer.CloseWithError(output.out.Flush())
+	}()
+
+	return reader, nil
+}
+
+// StreamLines writes the selected lines of a haste while it is being downloaded, so the rest of a haste does not have
+// to be downloaded once the last selected line has been written
+// Chunked and binary hastes cannot be streamed; they are retrieved with the getter instead. The getter is also used if
+// no streamer is provided.
+func StreamLines(key string, streamer server.HasteStreamer, getter server.HasteGetter, selection Selection,
+	out io.Writer) error {
+	var input io.Reader
+	if streamer != nil {
+		body, err := streamer.Stream(key, &http.Client{})
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		buffered := bufio.NewReader(body)
+		head, _ := buffered.Peek(len(envelopeHeader))
+		if !bytes.HasPrefix(head, []byte(envelopeHeader)) && !bytes.HasPrefix(bytes.TrimSpace(head), []byte("{")) {
+			input = buffered
+		}
+	}
+
+	if input == nil {
+		// possibly a manifest or an envelope
+		document, err := Fetch(key, getter)
+		if err != nil {
+			return err
+		}
+		if document.Envelope != nil {
+			return fmt.Errorf("Lines cannot be selected from binary hastes")
+		}
+		input = bytes.NewReader(document.Content)
+	}
+
+	selected, err := SelectLines(input, selection)
+	if err != nil {
+		return err
+	}
+	defer selected.Close()
+
+	if _, err := io.Copy(out, selected); err != nil {
+		return fmt.Errorf("Error writing haste: %s", err.Error())
+	}
+
+	return nil
+}
+
+// #region Private
+
+// lineStage receives the lines of an input and markers for lines that have been omitted by a previous stage
+type lineStage interface {
+	line(text string)
+	omit(count int)
+	end()
+	// full reports whether no further lines can be selected
+	full() bool
+}
+
+// outputStage writes lines and merges subsequent omissions into a single marker
+type outputStage struct {
+	out      *bufio.Writer
+	omitted  int
+	complete bool
+	quiet    bool
+}
+
+func (stage *outputStage) line(text string) {
+	stage.writeMarker()
+	stage.out.WriteString(text)
+	stage.complete = text[len(text)-1] == '\n'
+}
+
+func (stage *outputStage) omit(count int) {
+	stage.omitted += count
+}
+
+func (stage *outputStage) end() {
+	stage.writeMarker()
+}
+
+func (stage *outputStage) full() bool {
+	return false
+}
+
+func (stage *outputStage) writeMarker() {
+	if stage.omitted == 0 || stage.quiet {
+		stage.omitted = 0
+		return
+	}
+
+	if !stage.complete {
+		stage.out.WriteString("\n")
+	}
+	lines := "lines"
+	if stage.omitted == 1 {
+		lines = "line"
+	}
+	fmt.Fprintf(stage.out, "[... %d %s omitted ...]\n", stage.omitted, lines)
+	stage.omitted = 0
+	stage.complete = true
+}
+
+type rangeStage struct {
+	from, to int
+	number   int
+	next     lineStage
+}
+
+func (stage *rangeStage) line(text string) {
+	stage.number++
+	if stage.number < stage.from || (stage.to > 0 && stage.number > stage.to) {
+		stage.next.omit(1)
+		return
+	}
+
+	stage.next.line(text)
+}
+
+func (stage *rangeStage) omit(count int) {
+	stage.number += count
+	stage.next.omit(count)
+}
+
+func (stage *rangeStage) end() {
+	stage.next.end()
+}
+
+func (stage *rangeStage) full() bool {
+	return (stage.to > 0 && stage.number >= stage.to) || stage.next.full()
+}
+
+type grepStage struct {
+	pattern *regexp.Regexp
+	context int
+	// before holds the last non-matching lines, which are kept if one of the next lines matches
+	before []string
+	after  int
+	next   lineStage
+}
+
+func (stage *grepStage) line(text string) {
+	if stage.pattern.MatchString(strings.TrimRight(text, "\r\n")) {
+		for _, before := range stage.before {
+			stage.next.line(before)
+		}
+		stage.before = nil
+		stage.next.line(text)
+		stage.after = stage.context
+		return
+	}
+
+	if stage.after > 0 {
+		stage.after--
+		stage.next.line(text)
+		return
+	}
+
+	stage.before = append(stage.before, text)
+	if len(stage.before) > stage.context {
+		stage.before = stage.before[1:]
+		stage.next.omit(1)
+	}
+}
+
+func (stage *grepStage) omit(count int) {
+	// context does not reach across omitted lines
+	stage.next.omit(len(stage.before) + count)
+	stage.before = nil
+	stage.after = 0
+}
+
+func (stage *grepStage) end() {
+	stage.next.omit(len(stage.before))
+	stage.next.end()
+}
+
+func (stage *grepStage) full() bool {
+	return stage.next.full()
+}
+
+type headStage struct {
+	count   int
+	lines   int
+	omitted int
+	next    lineStage
+}
+
+func (stage *headStage) line(text string) {
+	if stage.lines >= stage.count {
+		stage.omitted++
+		return
+	}
+
+	stage.lines++
+	stage.next.line(text)
+}
+
+func (stage *headStage) omit(count int) {
+	if stage.lines >= stage.count {
+		stage.omitted += count
+		return
+	}
+
+	stage.next.omit(count)
+}
+
+func (stage *headStage) end() {
+	stage.next.omit(stage.omitted)
+	stage.next.end()
+}
+
+func (stage *headStage) full() bool {
+	return stage.lines >= stage.count
+}
+
+// tailStage keeps the last lines and the omissions between them until the input ends
+type tailStage struct {
+	count   int
+	lines   int
+	items   []tailItem
+	omitted int
+	next    lineStage
+}
+
+type tailItem struct {
+	text    string
+	omitted int
+}
+
+func (stage *tailStage) line(text string) {
+	stage.items = append(stage.items, tailItem{text: text})
+	stage.lines++
+
+	for stage.lines > stage.count {
+		dropped := stage.items[0]
+		stage.items = stage.items[1:]
+		if dropped.text == "" {
+			stage.omitted += dropped.omitted
+		} else {
+			stage.omitted++
+			stage.lines--
+		}
+	}
+}
+
+func (stage *tailStage) omit(count int) {
+	// subsequent omissions are kept as one item, so input without selected lines does not pile up items
+	if last := len(stage.items) - 1; last >= 0 && stage.items[last].text == "" {
+		stage.items[last].omitted += count
+		return
+	}
+
+	stage.items = append(stage.items, tailItem{omitted: count})
+}
+
+func (stage *tailStage) end() {
+	stage.next.omit(stage.omitted)
+	for _, item := range stage.items {
+		if item.text == "" {
+			stage.next.omit(item.omitted)
+		} else {
+			stage.next.line(item.text)
+		}
+	}
+	stage.next.end()
+}
+
+func (stage *tailStage) full() bool {
+	return false
+}
+
+// #endregion
@@ -0,0 +1,236 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// #region Setup
+
+// FakeStreamer streams a haste and records how much of it has been read
+type FakeStreamer struct {
+	haste string
+	read  *int
+}
+
+func (fake FakeStreamer) Stream(_ string, _ *http.Client) (io.ReadCloser, error) {
+	return ioutil.NopCloser(&countingReader{reader: strings.NewReader(fake.haste), read: fake.read}), nil
+}
+
+type countingReader struct {
+	reader io.Reader
+	read   *int
+}
+
+func (counting *countingReader) Read(p []byte) (int, error) {
+	// small reads, so reading can stop early
+	if len(p) > 16 {
+		p = p[:16]
+	}
+
+	n, err := counting.reader.Read(p)
+	*counting.read += n
+	return n, err
+}
+
+// #endregion
+
+func numberedLines(count int) string {
+	var lines strings.Builder
+	for i := 1; i <= count; i++ {
+		fmt.Fprintf(&lines, "line %d\n", i)
+	}
+
+	return lines.String()
+}
+
+func TestSelectLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		selection Selection
+		expected  string
+	}{
+		{"should select the first lines", numberedLines(5), Selection{Head: 2},
+			"line 1\nline 2\n[... 3 lines omitted ...]\n"},
+		{"should select the last lines", numberedLines(5), Selection{Tail: 2},
+			"[... 3 lines omitted ...]\nline 4\nline 5\n"},
+		{"should not mark anything if nothing is omitted", numberedLines(2), Selection{Tail: 5},
+			"line 1\nline 2\n"},
+		{"should select a range", numberedLines(6), Selection{From: 2, To: 3},
+			"[... 1 line omitted ...]\nline 2\nline 3\n[... 3 lines omitted ...]\n"},
+		{"should select an open range", numberedLines(4), Selection{From: 3},
+			"[... 2 lines omitted ...]\nline 3\nline 4\n"},
+		{"should select matching lines with context", numberedLines(12),
+			Selection{Grep: regexp.MustCompile(`line (4|11)$`), Context: 1},
+			"[... 2 lines omitted ...]\nline 3\nline 4\nline 5\n[... 4 lines omitted ...]\nline 10\nline 11\nline 12\n"},
+		{"should merge overlapping context", numberedLines(6),
+			Selection{Grep: regexp.MustCompile(`line (2|4)$`), Context: 1},
+			"line 1\nline 2\nline 3\nline 4\nline 5\n[... 1 line omitted ...]\n"},
+		{"should combine range, pattern and tail", numberedLines(20),
+			Selection{From: 5, To: 15, Grep: regexp.MustCompile(`[02468]$`), Tail: 2},
+			"[... 11 lines omitted ...]\nline 12\n[... 1 line omitted ...]\nline 14\n[... 6 lines omitted ...]\n"},
+		{"should end an incomplete last line before the marker", "a\nb\nc", Selection{From: 2, To: 3},
+			"[... 1 line omitted ...]\nb\nc"},
+		{"should mark lines after an incomplete last line", "a\nb", Selection{Head: 1},
+			"a\n[... 1 line omitted ...]\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, err := SelectLines(strings.NewReader(test.input), test.selection)
+			if err != nil {
+				t.Fatalf("Should not have returned error: %s", err.Error())
+			}
+
+			selected, err := ioutil.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("Should not have returned error: %s", err.Error())
+			}
+
+			if string(selected) != test.expected {
+				t.Errorf("Expected\n%q\ngot\n%q", test.expected, selected)
+			}
+		})
+	}
+
+	t.Run("should merge subsequent omissions before the last lines", func(t *testing.T) {
+		output := &outputStage{}
+		stage := &tailStage{count: 2, next: output}
+		stage.line("a\n")
+		for i := 0; i < 1000; i++ {
+			stage.omit(1)
+		}
+
+		if len(stage.items) != 2 || stage.items[1].omitted != 1000 {
+			t.Errorf("Expected the omissions to be merged into one item, got %d items", len(stage.items))
+		}
+	})
+
+	t.Run("should refuse binary input", func(t *testing.T) {
+		input := EncodeEnvelope(strings.NewReader("\x00\x01"), Envelope{})
+		if _, err := SelectLines(input, Selection{Head: 1}); err == nil {
+			t.Errorf("Should have returned an error")
+		}
+	})
+
+	t.Run("should refuse head and tail together", func(t *testing.T) {
+		if _, err := SelectLines(strings.NewReader(""), Selection{Head: 1, Tail: 1}); err == nil {
+			t.Errorf("Should have returned an error")
+		}
+	})
+}
+
+func TestParseLineRange(t *testing.T) {
+	tests := []struct {
+		arg      string
+		path     string
+		from, to int
+		err      bool
+	}{
+		{"main.go", "main.go", 0, 0, false},
+		{"main.go:40-90", "main.go", 40, 90, false},
+		{"main.go:40-", "main.go", 40, 0, false},
+		{"main.go:40", "main.go", 40, 40, false},
+		{"main.go:90-40", "", 0, 0, true},
+		{"main.go:0", "", 0, 0, true},
+		{`C:\logs\app.log`, `C:\logs\app.log`, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		path, from, to, err := ParseLineRange(test.arg)
+		if (err != nil) != test.err || path != test.path || from != test.from || to != test.to {
+			t.Errorf("%s: Expected %s %d-%d (error: %t), got %s %d-%d (%v)", test.arg, test.path, test.from, test.to,
+				test.err, path, from, to, err)
+		}
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	tests := []struct {
+		spec     string
+		from, to int
+		err      bool
+	}{
+		{"10:40", 10, 40, false},
+		{"10-40", 10, 40, false},
+		{"L10-L40", 10, 40, false},
+		{"L10", 10, 10, false},
+		{"10:", 10, 0, false},
+		{":40", 1, 40, false},
+		{"40:10", 0, 0, true},
+		{"abc", 0, 0, true},
+		{"", 0, 0, true},
+	}
+
+	for _, test := range tests {
+		from, to, err := ParseLines(test.spec)
+		if (err != nil) != test.err || from != test.from || to != test.to {
+			t.Errorf("%s: Expected %d-%d (error: %t), got %d-%d (%v)", test.spec, test.from, test.to, test.err, from, to, err)
+		}
+	}
+}
+
+func TestStreamLines(t *testing.T) {
+	t.Run("should stop reading after the last selected line", func(t *testing.T) {
+		read := 0
+		haste := numberedLines(1000)
+		out := bytes.NewBufferString("")
+
+		err := StreamLines("key", FakeStreamer{haste: haste, read: &read}, FakeGetter{}, Selection{From: 2, To: 3, Quiet: true}, out)
+		if err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		if out.String() != "line 2\nline 3\n" {
+			t.Errorf(`Expected only the selected lines without markers, got "%s"`, out.String())
+		}
+		if read >= len(haste)/2 {
+			t.Errorf("Expected the haste not to be read completely, read %d of %d bytes", read, len(haste))
+		}
+	})
+
+	t.Run("should select from the last lines", func(t *testing.T) {
+		read := 0
+		out := bytes.NewBufferString("")
+
+		err := StreamLines("key", FakeStreamer{haste: numberedLines(5), read: &read}, FakeGetter{}, Selection{Tail: 2, Quiet: true}, out)
+		if err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		if out.String() != "line 4\nline 5\n" {
+			t.Errorf(`Expected the last lines, got "%s"`, out.String())
+		}
+	})
+
+	t.Run("should fall back to the getter for binary hastes", func(t *testing.T) {
+		read := 0
+		envelope, _ := ioutil.ReadAll(EncodeEnvelope(strings.NewReader("\x00\x01"), Envelope{}))
+		getter := FakeGetter{haste: string(envelope)}
+
+		err := StreamLines("key", FakeStreamer{haste: string(envelope), read: &read}, getter, Selection{Head: 1}, ioutil.Discard)
+		if err == nil || err.Error() != "Lines cannot be selected from binary hastes" {
+			t.Errorf("Expected binary hastes to be refused, got %v", err)
+		}
+	})
+
+	t.Run("should use the getter without a streamer", func(t *testing.T) {
+		out := bytes.NewBufferString("")
+
+		err := StreamLines("key", nil, FakeGetter{haste: numberedLines(3)}, Selection{Head: 1, Quiet: true}, out)
+		if err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		if out.String() != "line 1\n" {
+			t.Errorf(`Expected the first line, got "%s"`, out.String())
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// TeeReader passes everything that is read from the input through to an output while it is uploaded
+// Nothing is buffered: every chunk is written as soon as it has been read. If writing fails, e.g. because the reading
+// end of a pipe has been closed, the output is dropped but the input is still read, so the upload is complete.
+type TeeReader struct {
+	// Err is the first error that occurred writing to the output
+	Err error
+
+	input       io.Reader
+	out         io.Writer
+	interrupted int32
+}
+
+// Tee creates a reader that passes the input through to the output
+func Tee(input io.Reader, out io.Writer) *TeeReader {
+	return &TeeReader{input: input, out: out}
+}
+
+func (tee *TeeReader) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&tee.interrupted) != 0 {
+		return 0, io.EOF
+	}
+
+	n, err := tee.input.Read(p)
+	if n > 0 && tee.Err == nil {
+		if _, writeErr := tee.out.Write(p[:n]); writeErr != nil {
+			tee.Err = writeErr
+		}
+	}
+
+	if err != nil && err != io.EOF && atomic.LoadInt32(&tee.interrupted) != 0 {
+		// the input failed because its producer has been interrupted as well; keep what has been captured
+		return n, io.EOF
+	}
+
+	return n, err
+}
+
+// Interrupt ends the input after the current read, so what has been captured so far can still be uploaded
+func (tee *TeeReader) Interrupt() {
+	atomic.StoreInt32(&tee.interrupted, 1)
+}
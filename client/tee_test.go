@@ -0,0 +1,71 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// #region Setup
+
+type FakeBrokenWriter struct {
+	written int
+}
+
+func (fake *FakeBrokenWriter) Write(p []byte) (int, error) {
+	if fake.written > 0 {
+		return 0, fmt.Errorf("broken pipe")
+	}
+
+	fake.written += len(p)
+	return len(p), nil
+}
+
+// #endregion
+
+func TestTee(t *testing.T) {
+	t.Run("should pass the input through while it is read", func(t *testing.T) {
+		out := bytes.NewBufferString("")
+		tee := Tee(strings.NewReader("line 1\nline 2\n"), out)
+
+		buffer := make([]byte, 7)
+		if _, err := tee.Read(buffer); err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		if out.String() != "line 1\n" {
+			t.Errorf(`Expected the first read to be passed through immediately, got "%s"`, out.String())
+		}
+	})
+
+	t.Run("should keep reading if the output fails", func(t *testing.T) {
+		out := &FakeBrokenWriter{}
+		input := strings.Repeat("x", 100)
+		tee := Tee(iotest.OneByteReader(strings.NewReader(input)), out)
+
+		content, err := ioutil.ReadAll(tee)
+		if err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		if string(content) != input {
+			t.Errorf("Expected the complete input to be read, got %d bytes", len(content))
+		}
+		if tee.Err == nil || tee.Err.Error() != "broken pipe" {
+			t.Errorf("Expected the write error to be kept, got %v", tee.Err)
+		}
+	})
+
+	t.Run("should end the input when interrupted", func(t *testing.T) {
+		tee := Tee(strings.NewReader("content"), ioutil.Discard)
+		tee.Interrupt()
+
+		if n, err := tee.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+			t.Errorf("Expected EOF after an interrupt, got %d bytes and %v", n, err)
+		}
+	})
+}
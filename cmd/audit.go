@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jagoe/haste-client-go/audit"
+	"github.com/jagoe/haste-client-go/history"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// decisions are the policy decisions of the current command that are recorded in the audit log
+var decisions []string
+
+// NewAuditCommand creates a command that checks the audit log
+func NewAuditCommand() *cobra.Command {
+	auditCmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Check the audit log of created and retrieved hastes",
+		Long: `If an audit log is configured (auditLog), every created and retrieved haste is appended to it as a JSON line.
+	Every entry contains the hash of the previous one, so modified or removed entries can be detected.`,
+		Args: cobra.NoArgs,
+	}
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify [audit log]",
+		Short: "Verify the hash chain of the audit log",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path := viper.GetString("auditLog")
+			if len(args) > 0 {
+				path = args[0]
+			}
+
+			if path == "" {
+				fmt.Fprintln(cmd.ErrOrStderr(), "No audit log configured")
+				os.Exit(1)
+			}
+
+			count, err := audit.Log{Path: path}.Verify()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "%d entries verified\n", count)
+		},
+	}
+
+	auditCmd.AddCommand(verifyCmd)
+
+	return auditCmd
+}
+
+// decide notes a policy decision for the audit log
+func decide(format string, args ...interface{}) {
+	decisions = append(decisions, fmt.Sprintf(format, args...))
+}
+
+// recordAudit appends an entry to the audit log if one is configured
+// The haste has already been transferred at this point, so a failure is reported with a non-zero exit code.
+func recordAudit(cmd *cobra.Command, entry history.Entry) {
+	path := viper.GetString("auditLog")
+	if path == "" {
+		return
+	}
+
+	auditDecisions := decisions
+	if orgPolicy != nil {
+		auditDecisions = append([]string{fmt.Sprintf("organization policy %s", orgPolicy.Path)}, auditDecisions...)
+	}
+
+	user, host := audit.CurrentUser()
+	err := audit.Log{Path: path}.Append(audit.Entry{
+		Time:      entry.Time,
+		User:      user,
+		Host:      host,
+		Action:    entry.Action,
+		Server:    entry.Server,
+		Key:       entry.Key,
+		SHA256:    entry.SHA256,
+		Size:      entry.Size,
+		Source:    entry.Source,
+		Decisions: auditDecisions,
+	})
+	decisions = nil
+
+	if err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jagoe/haste-client-go/audit"
+	"github.com/spf13/viper"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "haste-audit")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.jsonl")
+
+	url, err := create("audited\n", t, "--secrets", "redact")
+	if err != nil {
+		t.Fatalf(`Error creating haste: %s`, err.Error())
+	}
+
+	// the audit log is only configured in the config file, so it is set for this test only
+	config := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(config, []byte("auditLog: "+path+"\n"), 0600)
+	defer func() {
+		cfgFile = ""
+		viper.Set("auditLog", "")
+	}()
+
+	if _, err := get(url, t, "-c", config); err != nil {
+		t.Fatalf(`Error reading haste: %s`, err.Error())
+	}
+	if _, err := create("audited again\n", t, "-c", config); err != nil {
+		t.Fatalf(`Error creating haste: %s`, err.Error())
+	}
+
+	content, _ := ioutil.ReadFile(path)
+	lines := bytes.Split(bytes.TrimSpace(content), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 audit entries, got %d", len(lines))
+	}
+
+	var entry audit.Entry
+	json.Unmarshal(lines[1], &entry)
+	if entry.Action != "create" || entry.Size != 14 || len(entry.Decisions) == 0 {
+		t.Errorf("Expected the upload to be audited with its decisions, got %+v", entry)
+	}
+
+	output, err := execute(t, "audit", "verify", path)
+	if err != nil || output != "2 entries verified\n" {
+		t.Errorf("Expected the audit log to be verified, got '%s', %v", output, err)
+	}
+}
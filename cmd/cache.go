@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jagoe/haste-client-go/cache"
+	"github.com/jagoe/haste-client-go/history"
+	"github.com/jagoe/haste-client-go/server"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// NewCacheCommand creates a command that manages the cache of retrieved hastes
+func NewCacheCommand() *cobra.Command {
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the cache of retrieved hastes",
+		Long: `Retrieved hastes are cached in the XDG cache directory ($XDG_CACHE_HOME/haste-client-go by default) and revalidated
+	with the server when they are read again. Use --offline to read hastes from the cache only.`,
+		Args: cobra.NoArgs,
+	}
+
+	lsCmd := &cobra.Command{
+		Use:   "ls",
+		Short: "List cached hastes",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			items, err := hasteCache().List()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(writer, "URL\tSIZE\tFETCHED\tACCESSED")
+			for _, item := range items {
+				fmt.Fprintf(writer, "%s/%s\t%d\t%s\t%s\n", item.Server, item.Key, item.Size,
+					item.Fetched.Local().Format("2006-01-02 15:04:05"), item.Accessed.Local().Format("2006-01-02 15:04:05"))
+			}
+			writer.Flush()
+		},
+	}
+
+	clearCmd := &cobra.Command{
+		Use:   "clear",
+		Short: "Remove all cached hastes",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := hasteCache().Clear(); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove hastes that have not been used recently or exceed the size cap",
+		Example: `haste cache prune
+	haste cache prune --older-than 30d
+	haste cache prune --max-size 10000000`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			olderThan, err := history.ParseTime(cmd.Flag("older-than").Value.String(), time.Now())
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			hasteCache := hasteCache()
+			maxSize, _ := cmd.Flags().GetInt64("max-size")
+			if maxSize <= 0 {
+				maxSize = hasteCache.MaxSize
+			}
+
+			removed, err := hasteCache.Prune(olderThan, maxSize)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "Removed %d hastes\n", removed)
+		},
+	}
+	pruneCmd.Flags().String("older-than", "", "Remove hastes that have not been used since a date or duration (e.g. 30d)")
+	pruneCmd.Flags().Int64("max-size", 0, "Evict hastes until the cache fits into this many bytes [cacheMaxSize]")
+
+	cacheCmd.AddCommand(lsCmd, clearCmd, pruneCmd)
+
+	return cacheCmd
+}
+
+// cachedGetter wraps a server so retrieved hastes are cached unless caching is disabled
+// Servers that are not allowed by the organization policy are refused, including servers of URLs passed to get.
+func cachedGetter(hasteServer server.HasteServer) server.HasteGetter {
+	if err := orgPolicy.CheckServer(hasteServer.URL); err != nil {
+		return deniedGetter{err: err}
+	}
+
+	hasteCache := hasteCache()
+	if hasteCache.Dir == "" || (viper.GetBool("noCache") && !viper.GetBool("offline")) {
+		return hasteServer
+	}
+
+	return cache.Getter{
+		Getter:  hasteServer,
+		Server:  hasteServer.URL,
+		Cache:   hasteCache,
+		Offline: viper.GetBool("offline"),
+	}
+}
+
+func hasteCache() cache.Cache {
+	hasteCache := cache.Cache{Dir: viper.GetString("cacheDir"), MaxSize: viper.GetInt64("cacheMaxSize")}
+	if hasteCache.Dir == "" {
+		// without a cache directory, hastes are simply not cached
+		if dir, err := cache.DefaultDir(); err == nil {
+			hasteCache.Dir = dir
+		}
+	}
+	if hasteCache.MaxSize <= 0 {
+		hasteCache.MaxSize = cache.DefaultMaxSize
+	}
+
+	return hasteCache
+}
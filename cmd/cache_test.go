@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOfflineCache(t *testing.T) {
+	originalHaste := "Cached haste"
+	url, err := create(originalHaste, t)
+	if err != nil {
+		t.Fatalf(`Error creating haste: %s`, err.Error())
+	}
+
+	if _, err := get(url, t); err != nil {
+		t.Fatalf(`Error reading haste: %s`, err.Error())
+	}
+
+	// remove the haste from the server, so it can only be served from the cache
+	key := url[strings.LastIndex(url, "/")+1:]
+	delete(hastes, key)
+
+	haste, err := get(url, t, "--offline")
+	if err != nil {
+		t.Fatalf(`Error reading haste offline: %s`, err.Error())
+	}
+
+	if haste != originalHaste {
+		t.Errorf(`Expected "%s" to be "%s"`, haste, originalHaste)
+	}
+
+	listing, err := execute(t, "cache", "ls")
+	if err != nil {
+		t.Fatalf(`Error listing cache: %s`, err.Error())
+	}
+
+	if !strings.Contains(listing, url) {
+		t.Errorf(`Expected cache listing to contain "%s", got "%s"`, url, listing)
+	}
+
+	if _, err := execute(t, "cache", "clear"); err != nil {
+		t.Fatalf(`Error clearing cache: %s`, err.Error())
+	}
+
+	listing, _ = execute(t, "cache", "ls")
+	if strings.Contains(listing, url) {
+		t.Errorf(`Expected cache to be empty, got "%s"`, listing)
+	}
+}
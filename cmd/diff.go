@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/jagoe/haste-client-go/client"
+	"github.com/jagoe/haste-client-go/diff"
+	"github.com/jagoe/haste-client-go/server"
+	"github.com/jagoe/haste-client-go/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// NewDiffCommand creates a command that compares two hastes or a haste and a local file
+func NewDiffCommand() *cobra.Command {
+	diffCmd := &cobra.Command{
+		Use:   "diff [a] [b]",
+		Short: "Compare two hastes or a haste and a local file",
+		Long: `Compare two hastes or a haste and a local file as a unified diff. Each side is a haste key, a URL, a local file
+	or - for STDIN. The diff is colorized on a terminal and can be uploaded as a new haste.`,
+		Example: `haste diff oyivuxonema ebuqokorad
+	haste diff https://hastebin.com/oyivuxonema ./config.yaml
+	kubectl get cm app -o yaml | haste diff oyivuxonema -
+	haste diff oyivuxonema ebuqokorad --word-diff
+	haste diff oyivuxonema ./config.yaml --upload`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			upload, _ := cmd.Flags().GetBool("upload")
+			stat, _ := cmd.Flags().GetBool("stat")
+			words, _ := cmd.Flags().GetBool("word-diff")
+			context, _ := cmd.Flags().GetInt("unified")
+			if context < 0 {
+				fmt.Fprintln(cmd.ErrOrStderr(), "--unified cannot be negative")
+				os.Exit(1)
+			}
+			if upload && stat {
+				fmt.Fprintln(cmd.ErrOrStderr(), "--stat cannot be uploaded")
+				os.Exit(1)
+			}
+			if args[0] == "-" && args[1] == "-" {
+				fmt.Fprintln(cmd.ErrOrStderr(), "Only one side can be read from STDIN")
+				os.Exit(1)
+			}
+
+			var printer *client.Printer
+			if upload {
+				var err error
+				printer, err = newPrinter(cmd.OutOrStdout())
+				if err == nil {
+					_, err = resultText(&client.Result{})
+				}
+				if err != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+					os.Exit(1)
+				}
+			}
+
+			names := make([]string, 2)
+			contents := make([][]byte, 2)
+			for i, arg := range args {
+				var err error
+				names[i], contents[i], err = readSide(cmd, arg)
+				if err != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+					os.Exit(1)
+				}
+			}
+
+			if bytes.IndexByte(contents[0], 0) >= 0 || bytes.IndexByte(contents[1], 0) >= 0 {
+				if !bytes.Equal(contents[0], contents[1]) {
+					fmt.Fprintf(cmd.OutOrStdout(), "Binary files %s and %s differ\n", names[0], names[1])
+				}
+				return
+			}
+
+			// a haste could otherwise inject escape sequences into the terminal
+			color := !upload && util.IsTerminal(cmd.OutOrStdout())
+			if color {
+				for i := range contents {
+					contents[i] = []byte(util.SanitizeTerminal(string(contents[i])))
+				}
+			}
+
+			edits := diff.Lines(string(contents[0]), string(contents[1]))
+			if stat {
+				summary := diff.Summarize(edits)
+				fmt.Fprint(cmd.OutOrStdout(), summary.Format(fmt.Sprintf("%s => %s", names[0], names[1]), color))
+				return
+			}
+
+			unified := diff.Unified(edits, names[0], names[1], diff.Options{Context: context, Color: color, Words: words})
+			if !upload {
+				fmt.Fprint(cmd.OutOrStdout(), unified)
+				return
+			}
+
+			if unified == "" {
+				fmt.Fprintln(cmd.ErrOrStderr(), "No differences, nothing has been uploaded")
+				return
+			}
+
+			uploadDiff(cmd, printer, unified, strings.Join(names, " "))
+		},
+	}
+
+	initDiffCommand(diffCmd)
+
+	return diffCmd
+}
+
+func initDiffCommand(cmd *cobra.Command) {
+	cmd.Flags().Bool("stat", false, "Only print the number of changed lines")
+	cmd.Flags().Bool("word-diff", false, "Mark changed words instead of changed lines")
+	cmd.Flags().IntP("unified", "U", diff.DefaultContext, "Number of unchanged lines around every change")
+	cmd.Flags().Bool("upload", false, "Upload the diff as a new haste and print its URL")
+}
+
+// readSide reads one side of a diff from STDIN, a local file or a haste and names it for the diff header
+// Existing local files take precedence over haste keys.
+func readSide(cmd *cobra.Command, arg string) (string, []byte, error) {
+	if arg == "-" {
+		content, err := ioutil.ReadAll(cmd.InOrStdin())
+		if err != nil {
+			return "", nil, fmt.Errorf("Error reading STDIN: %s", err.Error())
+		}
+
+		return "STDIN", content, nil
+	}
+
+	if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+		content, err := ioutil.ReadFile(arg)
+		if err != nil {
+			return "", nil, fmt.Errorf("Error reading input file: %s", err.Error())
+		}
+
+		return arg, content, nil
+	}
+
+	hasteServer, key := resolveRef(arg)
+	document, err := client.Fetch(key, cachedGetter(hasteServer))
+	if err != nil {
+		return "", nil, err
+	}
+	recordGet(cmd, hasteServer.URL, document, "")
+
+	return fmt.Sprintf("%s/%s", hasteServer.URL, key), document.Content, nil
+}
+
+// uploadDiff uploads a diff as a haste that is highlighted as a diff
+func uploadDiff(cmd *cobra.Command, printer *client.Printer, unified string, source string) {
+	hasteServer := server.MakeHasteServer()
+	viper.Unmarshal(&hasteServer)
+
+	if err := orgPolicy.CheckCreate(hasteServer.URL); err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+		os.Exit(1)
+	}
+
+	result, err := uploadInput(cmd, strings.NewReader(unified), "changes.diff", hasteServer)
+	if err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+		os.Exit(1)
+	}
+
+	if err := publish(cmd, cmd.OutOrStdout(), printer, result.WithExtension("diff"), source, ""); err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	rawURL, err := create("host: a\nport: 80\n", t, "--url", "raw")
+	if err != nil {
+		t.Fatalf(`Error creating haste: %s`, err.Error())
+	}
+	oldURL := strings.Replace(rawURL, "/raw/", "/", 1)
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(file, []byte("host: a\nport: 8080\n"), 0644); err != nil {
+		t.Fatalf(`Error creating file: %s`, err.Error())
+	}
+
+	t.Run("should print a unified diff of a haste and a file", func(t *testing.T) {
+		output, err := execute(t, "diff", oldURL, file)
+		if err != nil {
+			t.Fatalf(`Error running diff: %s`, err.Error())
+		}
+
+		expected := "--- " + oldURL + "\n+++ " + file + "\n@@ -1,2 +1,2 @@\n host: a\n-port: 80\n+port: 8080\n"
+		if output != expected {
+			t.Errorf("Expected\n%s\ngot\n%s", expected, output)
+		}
+	})
+
+	t.Run("should print the number of changed lines", func(t *testing.T) {
+		output, err := execute(t, "diff", oldURL, file, "--stat")
+		if err != nil {
+			t.Fatalf(`Error running diff: %s`, err.Error())
+		}
+
+		if !strings.Contains(output, "| 2 +-") || !strings.Contains(output, "1 insertions(+), 1 deletions(-)") {
+			t.Errorf(`Expected a summary of the changes, got "%s"`, output)
+		}
+	})
+
+	t.Run("should upload the diff", func(t *testing.T) {
+		output, err := execute(t, "-s", testServer.URL, "diff", oldURL, file, "--upload", "--url", "both", "--yes")
+		if err != nil {
+			t.Fatalf(`Error running diff: %s`, err.Error())
+		}
+
+		urls := strings.Split(output, "\n")
+		if len(urls) != 2 || !strings.HasSuffix(urls[0], ".diff") {
+			t.Fatalf(`Expected the view URL of a diff, got "%s"`, output)
+		}
+
+		uploaded, err := get(urls[1], t)
+		if err != nil {
+			t.Fatalf(`Error reading diff: %s`, err.Error())
+		}
+
+		if !strings.Contains(uploaded, "-port: 80\n+port: 8080\n") {
+			t.Errorf(`Expected the uploaded diff, got "%s"`, uploaded)
+		}
+	})
+}
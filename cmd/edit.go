@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/jagoe/haste-client-go/client"
+	"github.com/spf13/cobra"
+)
+
+// NewEditCommand creates a command that edits a haste and publishes the result as a new revision
+func NewEditCommand() *cobra.Command {
+	editCmd := &cobra.Command{
+		Use:   "edit [haste key or URL]",
+		Short: "Edit a haste and publish it as a new revision",
+		Long: `Fetch a haste, open it in $VISUAL or $EDITOR and upload the edited content as a new haste on the same server.
+	Hastes cannot be changed, so the new haste is recorded as a revision of the original one in the local history
+	(see haste history --revisions).`,
+		Example: `haste edit oyivuxonema
+	EDITOR=nano haste edit http://pastebin.com/oyivuxonema`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			printer, err := newPrinter(cmd.OutOrStdout())
+			if err == nil {
+				_, err = resultText(&client.Result{})
+			}
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			server, key := resolveRef(args[0])
+			if err := orgPolicy.CheckCreate(server.URL); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			document, err := client.Fetch(key, cachedGetter(server))
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+			if document.Envelope != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), "Binary hastes cannot be edited")
+				os.Exit(1)
+			}
+			recordGet(cmd, server.URL, document, "")
+
+			edited, err := editDocument(cmd, document)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			if bytes.Equal(edited, document.Content) {
+				fmt.Fprintln(cmd.ErrOrStderr(), "No changes, nothing has been uploaded")
+				return
+			}
+
+			result, err := uploadInput(cmd, bytes.NewReader(edited), editFilename(document), server)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			// haste-server ignores the extension of a key, so revisions are linked to the plain URL
+			parent := fmt.Sprintf("%s/%s", server.URL, strings.SplitN(key, ".", 2)[0])
+			if err := publish(cmd, cmd.OutOrStdout(), printer, result, "", parent); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	return editCmd
+}
+
+// editDocument opens the content of a haste in the editor and returns the saved content
+func editDocument(cmd *cobra.Command, document *client.Document) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "haste-edit-")
+	if err != nil {
+		return nil, fmt.Errorf("Error creating temporary file: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// the extension lets the editor pick the right syntax highlighting
+	path := filepath.Join(dir, editFilename(document))
+	if err := ioutil.WriteFile(path, document.Content, 0600); err != nil {
+		return nil, fmt.Errorf("Error creating temporary file: %s", err.Error())
+	}
+
+	editor := strings.Fields(editorCommand())
+	editorCmd := exec.Command(editor[0], append(editor[1:], path)...)
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = cmd.ErrOrStderr()
+	editorCmd.Stderr = cmd.ErrOrStderr()
+	if err := editorCmd.Run(); err != nil {
+		return nil, fmt.Errorf("Error running editor %s: %s", editor[0], err.Error())
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading edited haste: %s", err.Error())
+	}
+
+	return content, nil
+}
+
+// editorCommand determines the editor from $VISUAL or $EDITOR
+func editorCommand() string {
+	for _, variable := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(variable)); editor != "" {
+			return editor
+		}
+	}
+
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+
+	return "vi"
+}
+
+// editFilename names the temporary file after the haste, keeping the extension of its key or detected language
+func editFilename(document *client.Document) string {
+	name := filepath.Base(document.Key)
+	head := document.Content
+	if len(head) > client.DetectLength {
+		head = head[:client.DetectLength]
+	}
+	if extension := client.DetectExtension("", head); filepath.Ext(name) == "" && extension != "" {
+		name += "." + extension
+	}
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		name = "haste"
+	}
+
+	return name
+}
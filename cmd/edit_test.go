@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/jagoe/haste-client-go/history"
+)
+
+func TestEdit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("editor script requires a POSIX shell")
+	}
+
+	editor := filepath.Join(t.TempDir(), "editor.sh")
+	if err := ioutil.WriteFile(editor, []byte("#!/bin/sh\necho 'edited' >> \"$1\"\n"), 0700); err != nil {
+		t.Fatalf(`Error creating editor script: %s`, err.Error())
+	}
+
+	os.Setenv("VISUAL", editor)
+	defer os.Unsetenv("VISUAL")
+
+	original, err := create("Edit test\n", t)
+	if err != nil {
+		t.Fatalf(`Error creating haste: %s`, err.Error())
+	}
+
+	t.Run("should upload the edited haste as a new revision", func(t *testing.T) {
+		revision, err := execute(t, "edit", original)
+		if err != nil {
+			t.Fatalf(`Error editing haste: %s`, err.Error())
+		}
+
+		if revision == "" || revision == original {
+			t.Fatalf(`Expected a new haste, got "%s"`, revision)
+		}
+
+		content, err := get(revision, t)
+		if err != nil {
+			t.Fatalf(`Error reading revision: %s`, err.Error())
+		}
+
+		if content != "Edit test\nedited\n" {
+			t.Errorf(`Expected edited content, got "%s"`, content)
+		}
+
+		output, err := execute(t, "history", "--revisions", revision, "--json")
+		if err != nil {
+			t.Fatalf(`Error listing revisions: %s`, err.Error())
+		}
+
+		var revisions []history.Entry
+		if err := json.Unmarshal([]byte(output), &revisions); err != nil {
+			t.Fatalf(`Error parsing revisions: %s`, err.Error())
+		}
+
+		if len(revisions) != 2 || revisions[0].URL != original || revisions[1].Parent != original {
+			t.Errorf(`Expected the revision to be linked to "%s", got %+v`, original, revisions)
+		}
+	})
+
+	t.Run("should not upload unchanged hastes", func(t *testing.T) {
+		os.Setenv("VISUAL", "true")
+
+		output, err := execute(t, "edit", original)
+		if err != nil {
+			t.Fatalf(`Error editing haste: %s`, err.Error())
+		}
+
+		if strings.Contains(output, "http") {
+			t.Errorf(`Expected nothing to be uploaded, got "%s"`, output)
+		}
+	})
+}
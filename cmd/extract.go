@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jagoe/haste-client-go/client"
+	"github.com/jagoe/haste-client-go/server"
+	"github.com/jagoe/haste-client-go/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// defaultHosts are public haste-server instances whose links are always recognized
+var defaultHosts = []string{"https://hastebin.com", "https://www.toptal.com/developers/hastebin"}
+
+// NewExtractCommand creates a command that finds links to hastes in arbitrary text
+func NewExtractCommand() *cobra.Command {
+	extractCmd := &cobra.Command{
+		Use:   "extract [file...]",
+		Short: "Find links to hastes in text and optionally retrieve them",
+		Long: `Find all links to hastes in files or STDIN, e.g. chat exports or logs. Links are recognized for the configured
+	server, the servers allowed by the organization policy, hastebin.com and every --host. Duplicates are removed.`,
+		Example: `haste extract < chat.log
+	haste extract --host https://paste.example.com incident/*.txt
+	haste extract --output-dir ./hastes < chat.log`,
+		Args: cobra.ArbitraryArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			text, err := readText(cmd, args)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			anyHost, _ := cmd.Flags().GetBool("any-host")
+			links := util.FindHasteLinks(string(text), knownHosts(cmd), anyHost)
+			if len(links) == 0 {
+				fmt.Fprintln(cmd.ErrOrStderr(), "No links to hastes found")
+				os.Exit(1)
+			}
+
+			var paths map[string]string
+			var fetchErr error
+			if outputDir := cmd.Flag("output-dir").Value.String(); outputDir != "" {
+				refs := make([]string, len(links))
+				found := map[string]util.HasteLink{}
+				for i, link := range links {
+					refs[i] = link.Server + "/" + link.Key
+					found[refs[i]] = link
+				}
+
+				// the links have been split already; servers with a path prefix cannot be told apart from a URL again
+				resolve := func(ref string) (server.HasteServer, string) {
+					return linkServer(found[ref]), found[ref].Key
+				}
+
+				// failed hastes are listed without a file, so the table is printed either way
+				paths, fetchErr = getAll(cmd, refs, outputDir, resolve)
+			}
+
+			writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			if paths == nil {
+				fmt.Fprintln(writer, "URL\tSERVER\tKEY")
+			} else {
+				fmt.Fprintln(writer, "URL\tSERVER\tKEY\tFILE")
+			}
+			for _, link := range links {
+				row := fmt.Sprintf("%s\t%s\t%s", link.URL, link.Server, link.Key)
+				if paths != nil {
+					row += "\t" + paths[link.Server+"/"+link.Key]
+				}
+				fmt.Fprintln(writer, util.SanitizeTerminal(row))
+			}
+			writer.Flush()
+
+			if fetchErr != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), fetchErr.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	extractCmd.Flags().StringSlice("host", nil, "Additional haste-server URLs whose links are recognized")
+	extractCmd.Flags().Bool("any-host", false, "Recognize links to hastes on any host")
+	extractCmd.Flags().String("output-dir", "", "Directory to retrieve all found hastes into")
+	extractCmd.Flags().Int("workers", client.BulkWorkers, "Number of hastes that are retrieved concurrently")
+	extractCmd.Flags().BoolP("force", "f", false, "Replace existing output files")
+	extractCmd.Flags().Bool("no-clobber", false, "Keep existing output files and skip the haste")
+
+	return extractCmd
+}
+
+// knownHosts lists the servers whose links are recognized
+func knownHosts(cmd *cobra.Command) []string {
+	hosts, _ := cmd.Flags().GetStringSlice("host")
+	hosts = append(hosts, viper.GetString("server"))
+	if orgPolicy != nil {
+		hosts = append(hosts, orgPolicy.AllowedServers...)
+	}
+
+	return append(hosts, defaultHosts...)
+}
+
+// linkServer returns the server of a link that has been found in text
+// Links come from untrusted text, so the client certificate is only sent to the configured server.
+func linkServer(link util.HasteLink) server.HasteServer {
+	hasteServer := server.MakeHasteServer()
+	viper.Unmarshal(&hasteServer)
+
+	if !sameServer(hasteServer.URL, link.Server) {
+		hasteServer.ClientCertificatePath, hasteServer.ClientCertificateKeyPath = "", ""
+	}
+	hasteServer.URL = link.Server
+
+	return hasteServer
+}
+
+// readText reads all files or STDIN if there are none
+func readText(cmd *cobra.Command, paths []string) ([]byte, error) {
+	if len(paths) == 0 {
+		text, err := ioutil.ReadAll(cmd.InOrStdin())
+		if err != nil {
+			return nil, fmt.Errorf("Error reading input: %s", err.Error())
+		}
+
+		return text, nil
+	}
+
+	var readers []io.Reader
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading input file: %s", err.Error())
+		}
+		defer file.Close()
+
+		// separate files, so links at the end of one file and the start of the next one are not joined
+		readers = append(readers, file, bytes.NewReader([]byte("\n")))
+	}
+
+	text, err := ioutil.ReadAll(io.MultiReader(readers...))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading input file: %s", err.Error())
+	}
+
+	return text, nil
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jagoe/haste-client-go/util"
+)
+
+func TestExtract(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "haste-test")
+	defer os.RemoveAll(dir)
+
+	url, err := create("linked haste", t)
+	if err != nil {
+		t.Fatalf(`Error creating haste: %s`, err.Error())
+	}
+
+	chat := filepath.Join(dir, "chat.log")
+	ioutil.WriteFile(chat, []byte("see "+url+", again: "+url+".\nand https://github.com/jagoe/haste-client-go\n"), 0644)
+
+	target := filepath.Join(dir, "hastes")
+	output, err := execute(t, "extract", "--host", testServer.URL, "--output-dir", target, chat)
+	if err != nil {
+		t.Fatalf(`Error extracting links: %s`, err.Error())
+	}
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], url) {
+		t.Fatalf("Expected a single deduplicated link, got:\n%s", output)
+	}
+
+	key := url[strings.LastIndex(url, "/")+1:]
+	content, _ := ioutil.ReadFile(filepath.Join(target, key))
+	if string(content) != "linked haste" || !strings.HasSuffix(lines[1], filepath.Join(target, key)) {
+		t.Errorf(`Expected the haste to be retrieved into %s, got "%s"`, target, content)
+	}
+}
+
+func TestExtractFromServerWithPathPrefix(t *testing.T) {
+	prefixed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hb/raw/abcdef" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("prefixed haste"))
+	}))
+	defer prefixed.Close()
+
+	dir := t.TempDir()
+	chat := filepath.Join(dir, "chat.log")
+	ioutil.WriteFile(chat, []byte("see "+prefixed.URL+"/hb/abcdef\n"), 0644)
+
+	target := filepath.Join(dir, "hastes")
+	_, err := execute(t, "extract", "--host", prefixed.URL+"/hb", "--output-dir", target, "--no-cache", chat)
+	if err != nil {
+		t.Fatalf(`Error extracting links: %s`, err.Error())
+	}
+
+	content, _ := ioutil.ReadFile(filepath.Join(target, "abcdef"))
+	if string(content) != "prefixed haste" {
+		t.Errorf(`Expected the haste to be retrieved from below the path prefix, got "%s"`, content)
+	}
+}
+
+func TestLinkServer(t *testing.T) {
+	cmd := NewRootCommand()
+	cmd.ParseFlags([]string{"-s", "https://hastebin.local/", "--client-cert", "cert.pem", "--client-cert-key", "key.pem"})
+
+	configured := linkServer(util.HasteLink{Server: "https://HASTEBIN.local", Key: "abcdef"})
+	if configured.URL != "https://HASTEBIN.local" || configured.ClientCertificatePath != "cert.pem" ||
+		configured.ClientCertificateKeyPath != "key.pem" {
+		t.Errorf("Expected the client certificate for the configured server, got %+v", configured)
+	}
+
+	foreign := linkServer(util.HasteLink{Server: "https://elsewhere.local", Key: "abcdef"})
+	if foreign.URL != "https://elsewhere.local" || foreign.ClientCertificatePath != "" ||
+		foreign.ClientCertificateKeyPath != "" {
+		t.Errorf("Expected no client certificate for another host, got %+v", foreign)
+	}
+}
@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/jagoe/haste-client-go/client"
+	"github.com/jagoe/haste-client-go/highlight"
 	"github.com/jagoe/haste-client-go/server"
 	"github.com/jagoe/haste-client-go/util"
 	"github.com/spf13/cobra"
@@ -14,44 +20,132 @@ import (
 // NewGetCommand creates a command that represents the get command
 func NewGetCommand() *cobra.Command {
 	getCmd := &cobra.Command{
-		Use:   "get [haste key or URL]",
+		Use:   "get [haste key or URL...]",
 		Short: "Get a haste from the server",
 		Long: `Get a haste from the configured server (https://hastebin.com by default) by providing a key or directly from
-	a hastebin server by providing the complete URL (protocol required!).`,
+	a hastebin server by providing the complete URL (protocol required!). Several hastes can be retrieved concurrently
+	into a directory.`,
 		Example: `haste get oyivuxonema
-	haste get http://pastebin.com/oyivuxonema`,
-		Args: cobra.ExactArgs(1),
+	haste get http://pastebin.com/oyivuxonema
+	haste get oyivuxonema --extract ./files
+	haste get oyivuxonema#L10-L40
+	haste get oyivuxonema --lines 10:40
+	haste get oyivuxonema --tail 100
+	haste get oyivuxonema http://pastebin.com/ebuqokorad --output-dir ./hastes
+	haste get --from-file ./links.txt --output-dir ./hastes`,
+		Args: cobra.ArbitraryArgs,
 		Run: func(cmd *cobra.Command, args []string) {
-			server := server.MakeHasteServer()
-			viper.Unmarshal(&server)
+			// fail before anything is retrieved if the output format is invalid
+			if _, err := newPrinter(cmd.ErrOrStderr()); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
 
-			var filepath string
-			if cmd.Flag("out") == nil {
-				filepath = ""
-			} else {
-				filepath = cmd.Flag("out").Value.String()
+			if flagsChanged(cmd, "out") && flagsChanged(cmd, "remote-name") {
+				fmt.Fprintln(cmd.ErrOrStderr(), "Only one of --out and --remote-name can be used")
+				os.Exit(1)
 			}
 
-			output, err := client.SetupGetOutput(filepath, client.OsFileOpener{}, cmd.OutOrStdout())
+			refs, err := hasteRefs(cmd, args)
 			if err != nil {
 				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
 				os.Exit(1)
 			}
 
-			serverURL, key := util.ParseURL(args[0])
-			if serverURL == "" || key == "" {
-				// not a valid URL, must be a key - default to configured server and use the provided key
-				key = args[0]
-			} else {
-				// a valid URL - override the configured server and use the parsed key
-				server.URL = serverURL
+			selection, err := getSelection(cmd, refs[0])
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			outputDir := cmd.Flag("output-dir").Value.String()
+			if remoteName, _ := cmd.Flags().GetBool("remote-name"); remoteName && outputDir == "" && len(refs) > 1 {
+				outputDir = "."
+			}
+			if len(refs) > 1 || outputDir != "" {
+				if flagsChanged(cmd, "lines", "head", "tail") {
+					fmt.Fprintln(cmd.ErrOrStderr(), "--lines, --head and --tail only work with a single haste")
+					os.Exit(1)
+				}
+				if _, err := getAll(cmd, refs, outputDir, resolveRef); err != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+					os.Exit(1)
+				}
+				return
+			}
+
+			server, key := resolveRef(refs[0])
+			if !selection.IsEmpty() {
+				if err := getLines(cmd, server, key, selection); err != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+					os.Exit(1)
+				}
+				return
+			}
+
+			document, err := client.Fetch(key, cachedGetter(server))
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
 			}
 
-			err = client.Get(key, server, output)
+			extractDir := cmd.Flag("extract").Value.String()
+			if extractDir != "" {
+				err = extract(cmd, document, server, extractDir)
+				if err != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+					os.Exit(1)
+				}
+
+				printDocumentInfo(cmd, document, server.URL, extractDir)
+				recordGet(cmd, server.URL, document, extractDir)
+				return
+			}
+
+			filepath := client.ResolveOutputPath(outputFlag(cmd), document)
+
+			render, err := newRenderer(cmd, filepath != "")
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			output, err := setupOutput(cmd, filepath)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			if output.Skipped {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Skipped %s, it already exists\n", filepath)
+				return
+			}
+
+			rawOutput, _ := cmd.Flags().GetBool("raw-output")
+			if render.active() && document.Envelope == nil {
+				err = render.print(cmd, render.render(document, 1, rawOutput))
+			} else {
+				if filepath == "" && !rawOutput && util.IsTerminal(output.Writer) {
+					// a haste could otherwise rewrite the terminal title, fake a prompt or write to the clipboard
+					terminal := &util.TerminalWriter{Out: output}
+					if err = client.Write(document, terminal); err == nil {
+						err = terminal.Flush()
+					}
+				} else {
+					err = client.Write(document, output)
+				}
+			}
+			if err == nil {
+				err = output.Commit()
+			}
 			if err != nil {
+				output.Abort()
 				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
 				os.Exit(1)
 			}
+
+			printDocumentInfo(cmd, document, server.URL, filepath)
+			recordGet(cmd, server.URL, document, filepath)
 		},
 	}
 
@@ -61,5 +155,355 @@ func NewGetCommand() *cobra.Command {
 }
 
 func initGetCommand(cmd *cobra.Command) {
-	cmd.Flags().StringP("out", "o", "", "File path to save the haste")
+	cmd.Flags().StringP("out", "o", "", "File path to save the haste; binary hastes keep their original filename in directories")
+	cmd.Flags().BoolP("remote-name", "O", false, "Save the haste in the current directory under its original filename or key")
+	cmd.Flags().StringP("extract", "x", "", "Directory to extract the files of a multi-file haste into")
+	cmd.Flags().Bool("raw-output", false, "Print the haste to a terminal without escaping control sequences")
+	cmd.Flags().BoolP("force", "f", false, "Replace existing output files")
+	cmd.Flags().Bool("no-clobber", false, "Keep existing output files and skip the haste")
+	cmd.Flags().Bool("append", false, "Append to existing output files")
+	cmd.Flags().String("from-file", "", "Read haste keys or URLs from a file, one per line (- for STDIN)")
+	cmd.Flags().String("output-dir", "", "Directory to save several hastes into, named by key or original filename")
+	cmd.Flags().Int("workers", client.BulkWorkers, "Number of hastes that are retrieved concurrently")
+	cmd.Flags().String("lines", "", "Only print a range of lines, e.g. 10:40, 10: or :40; also set by #L10-L40 anchors")
+	cmd.Flags().Int("head", 0, "Only print the first lines")
+	cmd.Flags().Int("tail", 0, "Only print the last lines")
+	cmd.Flags().String("color", "auto", "Highlight the syntax of the haste: auto (on a terminal), always or never")
+	cmd.Flags().String("theme", highlight.DefaultTheme,
+		fmt.Sprintf("Color theme of the syntax highlighting (%s)", strings.Join(highlight.Names(), "|")))
+	cmd.Flags().Bool("line-numbers", true, "Number the lines of a haste printed to a terminal")
+	cmd.Flags().Bool("no-pager", false, "Do not page hastes printed to a terminal")
+
+	viper.BindPFlag("theme", cmd.Flags().Lookup("theme"))
+}
+
+// outputFlag returns the output path from --out or the current directory for --remote-name
+func outputFlag(cmd *cobra.Command) string {
+	if remoteName, _ := cmd.Flags().GetBool("remote-name"); remoteName {
+		return "." + string(os.PathSeparator)
+	}
+
+	return cmd.Flag("out").Value.String()
+}
+
+// hasteRefs collects the keys or URLs of the hastes to retrieve from the arguments and --from-file
+func hasteRefs(cmd *cobra.Command, args []string) ([]string, error) {
+	var input io.Reader
+	switch fromFile := cmd.Flag("from-file").Value.String(); fromFile {
+	case "":
+		input = strings.NewReader("")
+	case "-":
+		input = cmd.InOrStdin()
+	default:
+		file, err := os.Open(fromFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading haste list: %s", err.Error())
+		}
+		defer file.Close()
+		input = file
+	}
+
+	refs, err := client.ReadRefs(io.MultiReader(strings.NewReader(strings.Join(args, "\n")+"\n"), input))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("No haste key or URL provided")
+	}
+
+	return refs, nil
+}
+
+// resolveRef determines the server and key of a haste key or URL; anchors are ignored
+func resolveRef(ref string) (server.HasteServer, string) {
+	ref, _ = util.SplitAnchor(ref)
+	hasteServer := server.MakeHasteServer()
+	viper.Unmarshal(&hasteServer)
+
+	serverURL, key := util.ParseURL(ref)
+	if serverURL == "" || key == "" {
+		// not a valid URL, must be a key - default to configured server and use the provided key
+		return hasteServer, ref
+	}
+
+	// a valid URL - override the configured server and use the parsed key
+	hasteServer.URL = serverURL
+	return hasteServer, key
+}
+
+// getSelection determines the lines to retrieve from --lines, --head, --tail and #L anchors like #L10-L40
+// --lines takes precedence over an anchor.
+func getSelection(cmd *cobra.Command, ref string) (client.Selection, error) {
+	selection := client.Selection{Quiet: true}
+	selection.Head, _ = cmd.Flags().GetInt("head")
+	selection.Tail, _ = cmd.Flags().GetInt("tail")
+
+	lines := cmd.Flag("lines").Value.String()
+	if _, anchor := util.SplitAnchor(ref); lines == "" && strings.HasPrefix(anchor, "L") {
+		lines = anchor
+	}
+
+	if lines != "" {
+		var err error
+		if selection.From, selection.To, err = client.ParseLines(lines); err != nil {
+			return selection, err
+		}
+	}
+
+	if selection.Head > 0 && selection.Tail > 0 {
+		return selection, fmt.Errorf("Only one of --head and --tail can be used")
+	}
+
+	return selection, nil
+}
+
+// getLines prints the selected lines of a haste while it is being downloaded
+// The haste is streamed from the server unless it can only be read from the cache; it is not cached either way.
+func getLines(cmd *cobra.Command, hasteServer server.HasteServer, key string, selection client.Selection) error {
+	if cmd.Flag("extract").Value.String() != "" {
+		return fmt.Errorf("--extract cannot be used with a line selection")
+	}
+
+	filepath := client.ResolveOutputPath(outputFlag(cmd), &client.Document{Key: key})
+	output, err := setupOutput(cmd, filepath)
+	if err != nil {
+		return err
+	}
+
+	if output.Skipped {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Skipped %s, it already exists\n", filepath)
+		return nil
+	}
+
+	render, err := newRenderer(cmd, filepath != "")
+	if err != nil {
+		return err
+	}
+
+	var selected bytes.Buffer
+	var writer io.Writer = output
+	rawOutput, _ := cmd.Flags().GetBool("raw-output")
+	if render.active() {
+		// the selected lines are rendered once the selection is complete
+		writer = ioutil.Discard
+	} else if filepath == "" && !rawOutput && util.IsTerminal(output.Writer) {
+		writer = &util.TerminalWriter{Out: output}
+	}
+
+	var streamer server.HasteStreamer
+	if orgPolicy.CheckServer(hasteServer.URL) == nil && !viper.GetBool("offline") {
+		streamer = hasteServer
+	}
+
+	document := &client.Document{Key: key}
+	err = client.StreamLines(key, streamer, cachedGetter(hasteServer), selection, io.MultiWriter(writer, &selected))
+	if terminal, ok := writer.(*util.TerminalWriter); ok && err == nil {
+		err = terminal.Flush()
+	}
+	if err == nil && render.active() {
+		document.Content = selected.Bytes()
+		err = render.print(cmd, render.render(document, firstLine(selection), rawOutput))
+	}
+	if err == nil {
+		err = output.Commit()
+	}
+	if err != nil {
+		output.Abort()
+		return err
+	}
+
+	document.Content = selected.Bytes()
+	printDocumentInfo(cmd, document, hasteServer.URL, filepath)
+	recordGet(cmd, hasteServer.URL, document, filepath)
+
+	return nil
+}
+
+// firstLine returns the number of the first selected line; the lines of a tail are numbered from 1
+func firstLine(selection client.Selection) int {
+	if selection.From > 0 && selection.Tail == 0 {
+		return selection.From
+	}
+
+	return 1
+}
+
+// flagsChanged reports whether one of the flags has been set
+func flagsChanged(cmd *cobra.Command, names ...string) bool {
+	for _, name := range names {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getAll retrieves several hastes concurrently into a directory and reports the outcome of each of them
+// resolve determines the server and key of every ref, e.g. resolveRef. It returns the file every retrieved haste has
+// been saved to by its ref.
+func getAll(cmd *cobra.Command, refs []string, outputDir string,
+	resolve func(ref string) (server.HasteServer, string)) (map[string]string, error) {
+	if outputDir == "" {
+		return nil, fmt.Errorf("Retrieving several hastes requires --output-dir")
+	}
+	for _, name := range []string{"extract", "out"} {
+		if flag := cmd.Flag(name); flag != nil && flag.Value.String() != "" {
+			return nil, fmt.Errorf("--extract and --out cannot be used with several hastes; use --output-dir instead")
+		}
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("Error creating output directory: %s", err.Error())
+	}
+
+	servers := map[string]server.HasteServer{}
+	for _, ref := range refs {
+		servers[ref], _ = resolve(ref)
+	}
+
+	workers, _ := cmd.Flags().GetInt("workers")
+	results := client.FetchAll(refs, workers, func(ref string) (server.HasteGetter, string) {
+		hasteServer, key := resolve(ref)
+		return cachedGetter(hasteServer), key
+	})
+
+	paths := map[string]string{}
+	failed := 0
+	for _, result := range results {
+		path := ""
+		err := result.Err
+		if err == nil {
+			path = client.ResolveOutputPath(outputDir+string(os.PathSeparator), result.Document)
+			err = saveDocument(cmd, result.Document, path)
+		}
+
+		if err != nil {
+			failed++
+			fmt.Fprintf(cmd.ErrOrStderr(), "FAIL  %s: %s\n", util.SanitizeTerminal(result.Ref), err.Error())
+			continue
+		}
+
+		paths[result.Ref] = path
+		fmt.Fprintf(cmd.ErrOrStderr(), "OK    %s -> %s\n", util.SanitizeTerminal(result.Ref), util.SanitizeTerminal(path))
+		printDocumentInfo(cmd, result.Document, servers[result.Ref].URL, path)
+		recordGet(cmd, servers[result.Ref].URL, result.Document, path)
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "%d of %d hastes retrieved\n", len(results)-failed, len(results))
+	if failed > 0 {
+		return paths, fmt.Errorf("%d hastes could not be retrieved", failed)
+	}
+
+	return paths, nil
+}
+
+// printDocumentInfo describes a retrieved haste on STDERR if JSON or a template output is requested
+// STDOUT is reserved for the content of the haste.
+func printDocumentInfo(cmd *cobra.Command, document *client.Document, serverURL string, path string) {
+	printer, err := newPrinter(cmd.ErrOrStderr())
+	if err == nil {
+		err = printer.Print(cmd.ErrOrStderr(), client.MakeDocumentInfo(document, serverURL, path), "")
+	}
+
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", err.Error())
+	}
+}
+
+// saveDocument writes a fetched haste to a file
+func saveDocument(cmd *cobra.Command, document *client.Document, path string) error {
+	output, err := setupOutput(cmd, path)
+	if err != nil {
+		return err
+	}
+
+	if output.Skipped {
+		return nil
+	}
+
+	err = client.Write(document, output)
+	if err == nil {
+		err = output.Commit()
+	}
+	if err != nil {
+		output.Abort()
+	}
+
+	return err
+}
+
+// setupOutput opens the output of a fetched haste and asks before an existing file is replaced
+func setupOutput(cmd *cobra.Command, filepath string) (*client.Output, error) {
+	mode := client.OutputCreate
+	modes := 0
+	for flag, flagMode := range map[string]client.OutputMode{
+		"force": client.OutputForce, "no-clobber": client.OutputNoClobber, "append": client.OutputAppend} {
+		if set, _ := cmd.Flags().GetBool(flag); set {
+			mode = flagMode
+			modes++
+		}
+	}
+	if modes > 1 {
+		return nil, fmt.Errorf("Only one of --force, --no-clobber and --append can be used")
+	}
+
+	output, err := client.SetupGetOutput(filepath, mode, client.OsFileOpener{}, cmd.OutOrStdout())
+
+	var existsErr *client.OutputExistsError
+	if errors.As(err, &existsErr) && confirm(cmd, fmt.Sprintf("Replace %s?", filepath)) {
+		return client.SetupGetOutput(filepath, client.OutputForce, client.OsFileOpener{}, cmd.OutOrStdout())
+	}
+
+	return output, err
+}
+
+// extract writes the files of a multi-file or index haste into a directory and lists them on STDERR
+// Files of an index haste are only retrieved from the server of the index haste, so the client certificate is never
+// sent to a host the index links to.
+func extract(cmd *cobra.Command, document *client.Document, indexServer server.HasteServer, dir string) error {
+	var files []client.BundleFile
+	var err error
+	if client.IsIndex(document.Content) {
+		var entries []client.IndexEntry
+		entries, err = client.ParseIndex(document.Content)
+		if err == nil {
+			files, err = client.FetchIndex(entries, func(url string) (server.HasteGetter, string, error) {
+				key, err := indexEntryKey(indexServer, url)
+				return cachedGetter(indexServer), key, err
+			})
+		}
+	} else {
+		files, err = client.ParseBundle(document.Content)
+	}
+	if err != nil {
+		return err
+	}
+
+	written, err := client.ExtractBundle(files, dir)
+	for _, path := range written {
+		fmt.Fprintln(cmd.ErrOrStderr(), util.SanitizeTerminal(path))
+	}
+
+	return err
+}
+
+// indexEntryKey returns the key of an index entry, refusing entries that link to another server than the index haste
+func indexEntryKey(indexServer server.HasteServer, url string) (string, error) {
+	serverURL, key := util.ParseURL(url)
+	if serverURL == "" || key == "" {
+		return "", fmt.Errorf("invalid haste URL '%s'", url)
+	}
+
+	if !sameServer(serverURL, indexServer.URL) {
+		return "", fmt.Errorf("'%s' is not hosted on %s like the index haste", url, indexServer.URL)
+	}
+
+	return key, nil
+}
+
+// sameServer compares two server URLs, ignoring the case of scheme and host and a trailing slash
+func sameServer(first string, second string) bool {
+	return strings.EqualFold(strings.TrimSuffix(first, "/"), strings.TrimSuffix(second, "/"))
 }
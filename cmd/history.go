@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jagoe/haste-client-go/client"
+	"github.com/jagoe/haste-client-go/history"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// NewHistoryCommand creates a command that lists and deletes entries of the local history
+func NewHistoryCommand() *cobra.Command {
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "List hastes that have been created or read",
+		Long: `List hastes that have been created or read on this machine. The history is stored in the XDG data directory
+	($XDG_DATA_HOME/haste-client-go/history.jsonl by default).`,
+		Example: `haste history
+	haste history --since 7d --host hastebin.com
+	haste history --file main.go --json
+	haste history --key oyivuxonema --delete
+	haste history --revisions oyivuxonema`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			store, err := historyStore()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			if revisionsOf, _ := cmd.Flags().GetString("revisions"); revisionsOf != "" {
+				revisions, err := store.Revisions(revisionsOf)
+				if err != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+					os.Exit(1)
+				}
+
+				printEntries(cmd, revisions, printRevisions)
+				return
+			}
+
+			filter, err := historyFilter(cmd)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			if deleteEntries, _ := cmd.Flags().GetBool("delete"); deleteEntries {
+				all, _ := cmd.Flags().GetBool("all")
+				if filter == (history.Filter{}) && !all {
+					fmt.Fprintln(cmd.ErrOrStderr(), "Refusing to delete the complete history without --all")
+					os.Exit(1)
+				}
+
+				deleted, err := store.Delete(filter)
+				if err != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+					os.Exit(1)
+				}
+
+				fmt.Fprintf(cmd.ErrOrStderr(), "Deleted %d entries\n", deleted)
+				return
+			}
+
+			entries, err := store.List(filter)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			if limit, _ := cmd.Flags().GetInt("limit"); limit > 0 && len(entries) > limit {
+				entries = entries[len(entries)-limit:]
+			}
+
+			printEntries(cmd, entries, printHistory)
+		},
+	}
+
+	initHistoryCommand(historyCmd)
+
+	return historyCmd
+}
+
+// NewLastCommand creates a command that prints the URL of the most recently created haste
+func NewLastCommand() *cobra.Command {
+	lastCmd := &cobra.Command{
+		Use:   "last",
+		Short: "Print the URL of the most recently created haste",
+		Example: `haste last
+	haste last --any
+	haste get $(haste last)`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			store, err := historyStore()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			filter := history.Filter{Action: history.ActionCreate}
+			if includeGets, _ := cmd.Flags().GetBool("any"); includeGets {
+				filter.Action = ""
+			}
+
+			entry, err := store.Last(filter)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			fmt.Fprint(cmd.OutOrStdout(), entry.URL)
+		},
+	}
+
+	lastCmd.Flags().Bool("any", false, "Also consider hastes that have been read")
+
+	return lastCmd
+}
+
+func initHistoryCommand(cmd *cobra.Command) {
+	cmd.Flags().String("since", "", "Only show entries since a date (YYYY-MM-DD, RFC 3339) or duration (24h, 7d)")
+	cmd.Flags().String("until", "", "Only show entries before a date (YYYY-MM-DD, RFC 3339) or duration (24h, 7d)")
+	cmd.Flags().String("host", "", "Only show entries for servers containing this string")
+	cmd.Flags().String("file", "", "Only show entries for source files containing this string")
+	cmd.Flags().String("key", "", "Only show entries for this haste key or URL")
+	cmd.Flags().String("action", "", "Only show entries for this action: create|get")
+	cmd.Flags().IntP("limit", "n", 0, "Only show the most recent entries")
+	cmd.Flags().Bool("json", false, "Print entries as JSON")
+	cmd.Flags().Bool("delete", false, "Delete the matching entries")
+	cmd.Flags().Bool("all", false, "Allow --delete to delete the complete history")
+	cmd.Flags().String("revisions", "", "Show the revisions of a haste key or URL that have been created by haste edit")
+}
+
+// record adds an entry to the local history and the audit log
+func record(cmd *cobra.Command, entry history.Entry) {
+	recordHistory(cmd, entry)
+	recordAudit(cmd, entry)
+}
+
+// recordGet records a retrieved haste like it has been recorded when it was created, i.e. with size and checksum of the
+// haste as stored on the server rather than of the decoded content
+func recordGet(cmd *cobra.Command, serverURL string, document *client.Document, source string) {
+	entry := history.MakeEntry(history.ActionGet, serverURL, document.Key, source, document.Content)
+	if document.SHA256 != "" {
+		entry.Size, entry.SHA256 = document.Size, document.SHA256
+	}
+
+	record(cmd, entry)
+}
+
+// recordHistory adds an entry to the local history unless disabled; errors are reported but not fatal
+func recordHistory(cmd *cobra.Command, entry history.Entry) {
+	if viper.GetBool("noHistory") {
+		return
+	}
+
+	store, err := historyStore()
+	if err == nil {
+		err = store.Add(entry)
+	}
+
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", err.Error())
+	}
+}
+
+func historyStore() (history.Store, error) {
+	path := viper.GetString("historyFile")
+	if path != "" {
+		return history.Store{Path: path}, nil
+	}
+
+	path, err := history.DefaultPath()
+	if err != nil {
+		return history.Store{}, err
+	}
+
+	return history.Store{Path: path}, nil
+}
+
+func historyFilter(cmd *cobra.Command) (history.Filter, error) {
+	var filter history.Filter
+	var err error
+	now := time.Now()
+
+	since, _ := cmd.Flags().GetString("since")
+	if filter.Since, err = history.ParseTime(since, now); err != nil {
+		return filter, err
+	}
+
+	until, _ := cmd.Flags().GetString("until")
+	if filter.Until, err = history.ParseTime(until, now); err != nil {
+		return filter, err
+	}
+
+	filter.Server, _ = cmd.Flags().GetString("host")
+	filter.Source, _ = cmd.Flags().GetString("file")
+	filter.Key, _ = cmd.Flags().GetString("key")
+	filter.Action, _ = cmd.Flags().GetString("action")
+
+	return filter, nil
+}
+
+// printEntries prints history entries as JSON if requested or else as a table
+func printEntries(cmd *cobra.Command, entries []history.Entry, printTable func(*cobra.Command, []history.Entry)) {
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		if entries == nil {
+			entries = []history.Entry{}
+		}
+
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		encoder.Encode(entries)
+		return
+	}
+
+	printTable(cmd, entries)
+}
+
+func printHistory(cmd *cobra.Command, entries []history.Entry) {
+	writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "TIME\tACTION\tURL\tSIZE\tSOURCE")
+	for _, entry := range entries {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%s\n",
+			entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Action, entry.URL, entry.Size, entry.Source)
+	}
+	writer.Flush()
+}
+
+func printRevisions(cmd *cobra.Command, entries []history.Entry) {
+	writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "TIME\tURL\tPARENT")
+	for _, entry := range entries {
+		created := "-"
+		if !entry.Time.IsZero() {
+			created = entry.Time.Local().Format("2006-01-02 15:04:05")
+		}
+
+		parent := entry.Parent
+		if parent == "" {
+			parent = "-"
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", created, entry.URL, parent)
+	}
+	writer.Flush()
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jagoe/haste-client-go/history"
+)
+
+func TestHistoryAndLast(t *testing.T) {
+	url, err := create("History test", t)
+	if err != nil {
+		t.Fatalf(`Error creating haste: %s`, err.Error())
+	}
+
+	if _, err := get(url, t); err != nil {
+		t.Fatalf(`Error reading haste: %s`, err.Error())
+	}
+
+	last, err := execute(t, "last")
+	if err != nil {
+		t.Fatalf(`Error running last: %s`, err.Error())
+	}
+
+	if last != url {
+		t.Errorf(`Expected last haste to be "%s", got "%s"`, url, last)
+	}
+
+	output, err := execute(t, "history", "--json", "--key", url)
+	if err != nil {
+		t.Fatalf(`Error running history: %s`, err.Error())
+	}
+
+	var entries []history.Entry
+	if err := json.Unmarshal([]byte(output), &entries); err != nil {
+		t.Fatalf(`Error parsing history: %s`, err.Error())
+	}
+
+	if len(entries) != 2 || entries[0].Action != history.ActionCreate || entries[1].Action != history.ActionGet {
+		t.Fatalf(`Expected a create and a get entry, got %+v`, entries)
+	}
+
+	if entries[0].Size != int64(len("History test")) || entries[0].SHA256 != entries[1].SHA256 {
+		t.Errorf(`Expected size and checksum to be recorded, got %+v`, entries)
+	}
+
+	if _, err := execute(t, "history", "--key", url, "--delete"); err != nil {
+		t.Fatalf(`Error deleting history: %s`, err.Error())
+	}
+
+	output, _ = execute(t, "history", "--key", url)
+	if strings.Contains(output, url) {
+		t.Errorf(`Expected history entries to be deleted, got "%s"`, output)
+	}
+}
+
+func TestHistoryOfBinaryHaste(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "image.bin")
+	ioutil.WriteFile(input, []byte("\x00\x01binary"), 0644)
+
+	url, err := create("", t, input, "--url", "raw", "--yes")
+	if err != nil {
+		t.Fatalf(`Error creating haste: %s`, err.Error())
+	}
+	key := url[strings.LastIndex(url, "/")+1:]
+
+	if _, err := get(url, t, "--out", filepath.Join(t.TempDir(), "image.bin")); err != nil {
+		t.Fatalf(`Error reading haste: %s`, err.Error())
+	}
+
+	output, err := execute(t, "history", "--json", "--key", key)
+	if err != nil {
+		t.Fatalf(`Error running history: %s`, err.Error())
+	}
+
+	var entries []history.Entry
+	if err := json.Unmarshal([]byte(output), &entries); err != nil || len(entries) != 2 {
+		t.Fatalf(`Expected a create and a get entry, got "%s"`, output)
+	}
+
+	// both record the envelope that is stored on the server, not the decoded file
+	if entries[0].SHA256 != entries[1].SHA256 || entries[0].Size != entries[1].Size || entries[1].Size == 8 {
+		t.Errorf(`Expected create and get to record the same size and checksum, got %+v`, entries)
+	}
+}
+
+func execute(t *testing.T, args ...string) (string, error) {
+	output := bytes.NewBufferString("")
+
+	cmd := NewRootCommand()
+	cmd.SetArgs(args)
+	cmd.SetOut(output)
+	cmd.SetErr(nil)
+
+	err := cmd.Execute()
+
+	return output.String(), err
+}
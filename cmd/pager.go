@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/jagoe/haste-client-go/client"
+	"github.com/jagoe/haste-client-go/highlight"
+	"github.com/jagoe/haste-client-go/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// renderer prints hastes to a terminal with syntax highlighting, line numbers and a pager
+type renderer struct {
+	color       bool
+	lineNumbers bool
+	pager       bool
+	theme       highlight.Theme
+}
+
+// newRenderer determines how a haste is printed from --color, --theme, --line-numbers and --no-pager
+// Hastes are only rendered if they are printed to STDOUT; pipes get the raw haste unless --color=always is set.
+func newRenderer(cmd *cobra.Command, toFile bool) (renderer, error) {
+	terminal := !toFile && util.IsTerminal(cmd.OutOrStdout())
+
+	var r renderer
+	switch color := cmd.Flag("color").Value.String(); color {
+	case "auto":
+		r.color = terminal
+	case "always":
+		r.color = !toFile
+	case "never":
+	default:
+		return r, fmt.Errorf("Invalid color mode '%s': expected auto|always|never", color)
+	}
+
+	theme, err := highlight.Lookup(viper.GetString("theme"))
+	if err != nil {
+		return r, err
+	}
+	r.theme = theme
+
+	lineNumbers, _ := cmd.Flags().GetBool("line-numbers")
+	noPager, _ := cmd.Flags().GetBool("no-pager")
+	r.lineNumbers = terminal && lineNumbers
+	r.pager = terminal && !noPager
+
+	return r, nil
+}
+
+// active reports whether hastes are rendered instead of printed as they are
+func (r renderer) active() bool {
+	return r.color || r.lineNumbers || r.pager
+}
+
+// render highlights a haste in the language of its extension or detected from its content and numbers its lines
+// Control characters are escaped unless raw output is requested.
+func (r renderer) render(document *client.Document, first int, raw bool) string {
+	text := string(document.Content)
+	if !raw {
+		text = util.SanitizeTerminal(text)
+	}
+
+	if r.color && document.Envelope == nil {
+		text = highlight.Highlight(text, documentExtension(document), r.theme)
+	}
+
+	if r.lineNumbers && document.Envelope == nil {
+		theme := r.theme
+		if !r.color {
+			theme = highlight.Theme{}
+		}
+		text = highlight.NumberLines(text, first, theme)
+	}
+
+	return text
+}
+
+// documentExtension determines the language of a haste from the extension of its key or its content
+func documentExtension(document *client.Document) string {
+	if extension := strings.TrimPrefix(filepath.Ext(document.Key), "."); extension != "" {
+		return extension
+	}
+
+	head := document.Content
+	if len(head) > client.DetectLength {
+		head = head[:client.DetectLength]
+	}
+
+	return client.DetectExtension("", head)
+}
+
+// print writes a rendered haste to STDOUT, through a pager if enabled
+func (r renderer) print(cmd *cobra.Command, text string) error {
+	if !r.pager {
+		_, err := io.WriteString(cmd.OutOrStdout(), text)
+		return err
+	}
+
+	return page(cmd, text)
+}
+
+// page shows text in $PAGER, less or the built-in pager
+func page(cmd *cobra.Command, text string) error {
+	pager := strings.Fields(os.Getenv("PAGER"))
+	if len(pager) == 0 {
+		if _, err := exec.LookPath("less"); err == nil {
+			// quit if the haste fits on the screen, keep colors and do not clear the screen on exit
+			pager = []string{"less", "-FRX"}
+		}
+	}
+
+	if len(pager) == 0 || pager[0] == "builtin" {
+		tty, err := openTerminal()
+		if err != nil {
+			_, err = io.WriteString(cmd.OutOrStdout(), text)
+			return err
+		}
+		defer tty.Close()
+
+		return builtinPager(text, cmd.OutOrStdout(), tty, terminalHeight())
+	}
+
+	pagerCmd := exec.Command(pager[0], pager[1:]...)
+	pagerCmd.Stdin = strings.NewReader(text)
+	pagerCmd.Stdout = cmd.OutOrStdout()
+	pagerCmd.Stderr = cmd.ErrOrStderr()
+	if os.Getenv("LESS") == "" {
+		// like git, less keeps colors if it has been configured as $PAGER without -R
+		pagerCmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+
+	if err := pagerCmd.Run(); err != nil {
+		return fmt.Errorf("Error running pager %s: %s", pager[0], err.Error())
+	}
+
+	return nil
+}
+
+// builtinPager prints a screen of text at a time; Enter shows the next screen and q quits
+func builtinPager(text string, out io.Writer, tty io.Reader, height int) error {
+	input := bufio.NewReader(tty)
+	lines := strings.SplitAfter(text, "\n")
+	for start := 0; start < len(lines); start += height - 1 {
+		end := start + height - 1
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		if _, err := io.WriteString(out, strings.Join(lines[start:end], "")); err != nil {
+			return err
+		}
+		if end == len(lines) || (end == len(lines)-1 && lines[end] == "") {
+			return nil
+		}
+
+		fmt.Fprint(out, "\x1b[7m-- More -- (Enter: next page, q: quit)\x1b[0m")
+		answer, err := input.ReadString('\n')
+		fmt.Fprint(out, "\r\x1b[K")
+		if err != nil || strings.TrimSpace(strings.ToLower(answer)) == "q" {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// openTerminal opens the terminal for reading, since STDIN may be a pipe
+func openTerminal() (*os.File, error) {
+	terminal := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		terminal = "CONIN$"
+	}
+
+	return os.Open(terminal)
+}
+
+// terminalHeight returns the number of lines of the terminal from $LINES or a default of 24
+func terminalHeight() int {
+	if lines, err := strconv.Atoi(os.Getenv("LINES")); err == nil && lines > 2 {
+		return lines
+	}
+
+	return 24
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinPager(t *testing.T) {
+	text := "1\n2\n3\n4\n5\n6\n"
+	prompt := "\x1b[7m-- More -- (Enter: next page, q: quit)\x1b[0m\r\x1b[K"
+
+	tests := []struct {
+		input    string
+		height   int
+		expected string
+	}{
+		{"", 10, text},
+		{"\n\n", 4, "1\n2\n3\n" + prompt + "4\n5\n6\n"},
+		{"q\n", 4, "1\n2\n3\n" + prompt},
+		{"", 3, "1\n2\n" + prompt},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		if err := builtinPager(text, &out, strings.NewReader(test.input), test.height); err != nil {
+			t.Fatalf(`Error paging: %s`, err.Error())
+		}
+
+		if out.String() != test.expected {
+			t.Errorf(`%q with height %d: Expected %q, got %q`, test.input, test.height, test.expected, out.String())
+		}
+	}
+}
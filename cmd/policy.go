@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"net/http"
+
+	"github.com/jagoe/haste-client-go/policy"
+)
+
+var (
+	// policyFile is the location of the organization policy; it is not configurable, so users cannot bypass it
+	policyFile = policy.DefaultPath()
+	orgPolicy  *policy.Policy
+)
+
+// deniedGetter refuses to retrieve hastes from servers that are not allowed by the organization policy
+type deniedGetter struct {
+	err error
+}
+
+func (getter deniedGetter) Get(_ string, _ *http.Client) (string, error) {
+	return "", getter.err
+}
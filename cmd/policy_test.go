@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jagoe/haste-client-go/policy"
+	"github.com/jagoe/haste-client-go/server"
+)
+
+func TestCachedGetterWithPolicy(t *testing.T) {
+	orgPolicy = &policy.Policy{AllowedServers: []string{testServer.URL}}
+	defer func() { orgPolicy = nil }()
+
+	allowed := server.MakeHasteServer()
+	allowed.URL = testServer.URL
+	if _, ok := cachedGetter(allowed).(deniedGetter); ok {
+		t.Error("Expected the allowed server not to be refused")
+	}
+
+	denied := server.MakeHasteServer()
+	denied.URL = "https://hastebin.com"
+	if _, err := cachedGetter(denied).Get("abc", &http.Client{}); err == nil {
+		t.Error("Expected the server to be refused")
+	}
+}
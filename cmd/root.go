@@ -1,14 +1,26 @@
 package cmd
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/jagoe/haste-client-go/client"
+	"github.com/jagoe/haste-client-go/history"
+	"github.com/jagoe/haste-client-go/policy"
+	"github.com/jagoe/haste-client-go/scan"
 	"github.com/jagoe/haste-client-go/server"
+	"github.com/jagoe/haste-client-go/util"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
 )
@@ -21,13 +33,17 @@ var (
 // NewRootCommand creates a root command which represents the base command when called without any subcommands
 func NewRootCommand() *cobra.Command {
 	rootCmd := &cobra.Command{
-		Use:   "haste [file]",
+		Use:   "haste [file or directory...]",
 		Short: "A hastebin client, written in Go",
 		Long:  fmt.Sprintf("haste v%s\nA hastebin client that can create hastes from files and STDIN and read hastes from a haste-server instance.", version),
-		Args:  cobra.MaximumNArgs(1),
+		Args:  cobra.ArbitraryArgs,
 		Example: `echo Test | haste
 cat ./file | haste
-haste ./file`,
+haste ./file
+haste main.go go.mod ./logs/*.log
+haste main.go:40-90
+haste --tail 500 ./app.log
+haste ./dir`,
 		Run: func(cmd *cobra.Command, args []string) {
 			displayVersion := false
 			versionFlag := cmd.Flag("version")
@@ -47,23 +63,79 @@ haste ./file`,
 			server := server.MakeHasteServer()
 			viper.Unmarshal(&server)
 
-			var filepath string
-			if len(args) > 0 {
-				filepath = args[0]
-			} else {
-				filepath = ""
+			if err := orgPolicy.CheckCreate(server.URL); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			// STDOUT is reserved for the input in tee mode
+			tee, _ := cmd.Flags().GetBool("tee")
+			out := cmd.OutOrStdout()
+			if tee {
+				out = cmd.ErrOrStderr()
+			}
+
+			printer, err := newPrinter(out)
+			if err == nil {
+				_, err = resultText(&client.Result{})
 			}
-			input, err := client.SetupCreateInput(filepath, client.OsFileOpener{}, cmd.InOrStdin())
 			if err != nil {
 				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
 				os.Exit(1)
 			}
 
-			err = client.Create(input, server, server.URL, cmd.OutOrStdout())
+			extension, overridden, err := extensionOverride(cmd)
 			if err != nil {
 				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
 				os.Exit(1)
 			}
+
+			binaryPolicy, err := client.ParseBinaryPolicy(cmd.Flag("binary").Value.String())
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			args, selection, err := lineSelection(cmd, args)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			paths, err := client.ExpandPaths(args)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			if tee && len(paths) > 0 {
+				fmt.Fprintln(cmd.ErrOrStderr(), "--tee only works with input from STDIN")
+				os.Exit(1)
+			}
+
+			var result *client.Result
+			if len(paths) > 1 || isDirectory(paths) {
+				if !selection.IsEmpty() {
+					fmt.Fprintln(cmd.ErrOrStderr(), "--head, --tail, --grep and line ranges only work with a single input")
+					os.Exit(1)
+				}
+				result, err = createFromFiles(cmd, paths, binaryPolicy, server)
+			} else {
+				result, err = createFromInput(cmd, paths, binaryPolicy, selection, server)
+			}
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			if overridden {
+				result.WithExtension(extension)
+			}
+
+			if err := publish(cmd, out, printer, result, strings.Join(paths, " "), ""); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
 		},
 	}
 
@@ -91,19 +163,344 @@ func initRootCommand(rootCmd *cobra.Command) {
 	rootCmd.PersistentFlags().StringP("server", "s", "(global) https://hastebin.com", "Server URL")
 	rootCmd.PersistentFlags().String("client-cert", "", "(global) Client certificate path")
 	rootCmd.PersistentFlags().String("client-cert-key", "", "(global) Client certificate key path")
+	rootCmd.PersistentFlags().Int("max-size", server.DefaultMaxSize, "(global) Maximum haste size accepted by the server")
 	viper.BindPFlag("server", rootCmd.PersistentFlags().Lookup("server"))
 	viper.BindPFlag("clientCert", rootCmd.PersistentFlags().Lookup("client-cert"))
 	viper.BindPFlag("clientCertKey", rootCmd.PersistentFlags().Lookup("client-cert-key"))
+	rootCmd.PersistentFlags().Bool("no-history", false, "(global) Do not record hastes in the local history")
+	rootCmd.PersistentFlags().Bool("no-cache", false, "(global) Do not cache retrieved hastes")
+	rootCmd.PersistentFlags().Bool("offline", false, "(global) Only read hastes from the cache")
+	rootCmd.PersistentFlags().String("secrets", string(scan.PolicyWarn), "(global) What to do with possible secrets before uploading: off|block|warn|redact")
+	rootCmd.PersistentFlags().BoolP("yes", "y", false, "(global) Do not ask for confirmation")
+	rootCmd.PersistentFlags().String("output", string(client.OutputText), "(global) Output format of created and retrieved hastes: text|json")
+	rootCmd.PersistentFlags().String("format", "", "(global) Go template for the output, e.g. '{{.RawURL}}'")
+	rootCmd.PersistentFlags().String("url", "view", "(global) URLs of created hastes that are printed: raw|view|both")
+	viper.BindPFlag("maxSize", rootCmd.PersistentFlags().Lookup("max-size"))
+	viper.BindPFlag("noHistory", rootCmd.PersistentFlags().Lookup("no-history"))
+	viper.BindPFlag("noCache", rootCmd.PersistentFlags().Lookup("no-cache"))
+	viper.BindPFlag("offline", rootCmd.PersistentFlags().Lookup("offline"))
+	viper.BindPFlag("secrets.policy", rootCmd.PersistentFlags().Lookup("secrets"))
+	viper.BindPFlag("yes", rootCmd.PersistentFlags().Lookup("yes"))
+	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+	viper.BindPFlag("format", rootCmd.PersistentFlags().Lookup("format"))
+	viper.BindPFlag("url", rootCmd.PersistentFlags().Lookup("url"))
 
 	rootCmd.Flags().BoolP("version", "v", false, "Print the version number")
+	rootCmd.Flags().String("binary", string(client.BinaryEncode), "How to handle binary input: refuse|encode")
+	rootCmd.Flags().String("ext", "", "Extension of the view URL that haste-server highlights the haste by [detected]")
+	rootCmd.Flags().String("lang", "", "Language of the haste, e.g. python; sets the extension of the view URL")
+	rootCmd.Flags().Int("head", 0, "Only upload the first lines")
+	rootCmd.Flags().Int("tail", 0, "Only upload the last lines")
+	rootCmd.Flags().String("grep", "", "Only upload lines that match a regular expression")
+	rootCmd.Flags().IntP("context", "C", 0, "Number of lines around every line that matches --grep")
+	rootCmd.Flags().Bool("tee", false, "Pass STDIN through to STDOUT while uploading it; the URL is printed to STDERR")
+	rootCmd.Flags().Bool("index", false, "Upload every file of a directory separately and link them from an index haste")
+	rootCmd.Flags().Bool("chunked", false, "Split input that exceeds the maximum haste size into several hastes")
+	rootCmd.Flags().Int("chunk-size", 0, "Maximum size of a single chunk in bytes [--max-size]")
+	rootCmd.Flags().String("resume", "", "Resume a failed chunked upload from the given state file")
+}
+
+// createFromInput creates a haste from STDIN or a single file
+func createFromInput(cmd *cobra.Command, paths []string, binaryPolicy client.BinaryPolicy, selection client.Selection,
+	hasteServer server.HasteServer) (*client.Result, error) {
+	var filepath string
+	if len(paths) > 0 {
+		filepath = paths[0]
+	}
+
+	stdin := cmd.InOrStdin()
+	if tee, _ := cmd.Flags().GetBool("tee"); tee {
+		var stop func()
+		stdin, stop = teeInput(cmd)
+		defer stop()
+	}
+
+	input, err := client.SetupCreateInput(filepath, binaryPolicy, client.OsFileOpener{}, stdin)
+	if err == nil {
+		input, err = client.SelectLines(input, selection)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return uploadInput(cmd, input, filepath, hasteServer)
+}
+
+// uploadInput scans a single input for secrets and uploads it; the filename is used to detect its language
+func uploadInput(cmd *cobra.Command, input io.Reader, filepath string, hasteServer server.HasteServer) (*client.Result,
+	error) {
+	// the beginning of the input determines its language if it has no filename
+	buffered := bufio.NewReaderSize(input, client.DetectLength)
+	head, _ := buffered.Peek(client.DetectLength)
+	extension := client.DetectExtension(filepath, head)
+
+	input, done, err := scanSecrets(cmd, buffered)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := createHaste(cmd, input, hasteServer)
+	if err != nil {
+		return nil, done(err)
+	}
+
+	return result.WithExtension(extension), done(nil)
+}
+
+// publish prints the URLs of a created haste and records it; parent is the URL of the haste it has been edited from
+func publish(cmd *cobra.Command, out io.Writer, printer *client.Printer, result *client.Result, source string,
+	parent string) error {
+	text, _ := resultText(result)
+	if err := printer.Print(out, result, text); err != nil {
+		return err
+	}
+
+	record(cmd, history.Entry{
+		Time:   time.Now().UTC(),
+		Action: history.ActionCreate,
+		URL:    result.URL,
+		Key:    result.Key,
+		Server: result.Server,
+		Source: source,
+		Size:   result.Size,
+		SHA256: result.SHA256,
+		Parent: parent,
+	})
+
+	return nil
+}
+
+// createFromFiles packs several files and directories into a multi-file haste or uploads them with an index haste
+func createFromFiles(cmd *cobra.Command, paths []string, binaryPolicy client.BinaryPolicy,
+	hasteServer server.HasteServer) (*client.Result, error) {
+	files, skipped, err := client.LoadFiles(paths, int64(hasteServer.MaxSize), binaryPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	notes := map[string]string{}
+	var treePaths []string
+	for _, file := range skipped {
+		treePaths = append(treePaths, file.Path)
+		notes[file.Path] = fmt.Sprintf("(skipped: %s)", file.Reason)
+	}
+
+	if len(files) == 0 {
+		client.PrintTree(cmd.ErrOrStderr(), treePaths, notes)
+		return nil, fmt.Errorf("No files to upload")
+	}
+
+	files, err = scanFiles(cmd, files)
+	if err != nil {
+		return nil, err
+	}
+
+	index, _ := cmd.Flags().GetBool("index")
+	if !index {
+		for _, file := range files {
+			treePaths = append(treePaths, file.Path)
+		}
+		client.PrintTree(cmd.ErrOrStderr(), treePaths, notes)
+
+		return createHaste(cmd, client.WriteBundle(files), hasteServer)
+	}
+
+	result, entries, err := client.CreateIndex(files, hasteServer, hasteServer.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		treePaths = append(treePaths, entry.Path)
+		notes[entry.Path] = entry.URL
+	}
+	client.PrintTree(cmd.ErrOrStderr(), treePaths, notes)
+
+	return result, nil
+}
+
+// createHaste uploads the input as a single haste or in chunks if requested
+func createHaste(cmd *cobra.Command, input io.Reader, hasteServer server.HasteServer) (*client.Result, error) {
+	chunked, _ := cmd.Flags().GetBool("chunked")
+	if chunked {
+		return createChunked(cmd, input, hasteServer)
+	}
+
+	return client.Create(input, hasteServer, hasteServer.URL, ioutil.Discard)
+}
+
+// lineSelection determines the selected lines from --head, --tail, --grep and a line range of the file argument, e.g.
+// main.go:40-90; the range is removed from the returned arguments
+func lineSelection(cmd *cobra.Command, args []string) ([]string, client.Selection, error) {
+	var selection client.Selection
+	selection.Head, _ = cmd.Flags().GetInt("head")
+	selection.Tail, _ = cmd.Flags().GetInt("tail")
+	selection.Context, _ = cmd.Flags().GetInt("context")
+
+	if pattern, _ := cmd.Flags().GetString("grep"); pattern != "" {
+		grep, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, selection, fmt.Errorf("Invalid pattern '%s': %s", pattern, err.Error())
+		}
+		selection.Grep = grep
+	}
+
+	paths := make([]string, len(args))
+	for i, arg := range args {
+		path, from, to, err := client.ParseLineRange(arg)
+		if err != nil {
+			return nil, selection, err
+		}
+		if from > 0 && len(args) > 1 {
+			return nil, selection, fmt.Errorf("Line ranges only work with a single file")
+		}
+
+		paths[i] = path
+		selection.From, selection.To = from, to
+	}
+
+	return paths, selection, nil
+}
+
+// extensionOverride returns the extension provided with --ext or --lang
+func extensionOverride(cmd *cobra.Command) (string, bool, error) {
+	extension, _ := cmd.Flags().GetString("ext")
+	language, _ := cmd.Flags().GetString("lang")
+	if extension != "" && language != "" {
+		return "", false, fmt.Errorf("Only one of --ext and --lang can be used")
+	}
+
+	if language != "" {
+		extension, err := client.LanguageExtension(language)
+		return extension, err == nil, err
+	}
+
+	return extension, extension != "", nil
+}
+
+// resultText determines which URLs of a created haste are printed as text
+func resultText(result *client.Result) (string, error) {
+	switch mode := viper.GetString("url"); mode {
+	case "view", "":
+		return result.ViewURL, nil
+	case "raw":
+		return result.RawURL, nil
+	case "both":
+		return result.ViewURL + "\n" + result.RawURL, nil
+	default:
+		return "", fmt.Errorf("Invalid URL form '%s': expected raw|view|both", mode)
+	}
+}
+
+// newPrinter creates a printer for the configured output format; text output ends with a newline on terminals
+func newPrinter(out io.Writer) (*client.Printer, error) {
+	format := viper.GetString("output")
+	return client.NewPrinter(format, viper.GetString("format"), util.IsTerminal(out) || format == string(client.OutputJSON))
+}
+
+func isDirectory(paths []string) bool {
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// createChunked uploads the input as several chunks if it exceeds the maximum haste size of the server
+func createChunked(cmd *cobra.Command, input io.Reader, hasteServer server.HasteServer) (*client.Result, error) {
+	input, exceeds, err := client.ExceedsLimit(input, hasteServer.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exceeds {
+		return client.Create(input, hasteServer, hasteServer.URL, ioutil.Discard)
+	}
+
+	chunkSize, _ := cmd.Flags().GetInt("chunk-size")
+	if chunkSize <= 0 || chunkSize > hasteServer.MaxSize {
+		chunkSize = hasteServer.MaxSize
+	}
+
+	state := client.ChunkState{}
+	resumeFile, _ := cmd.Flags().GetString("resume")
+	if resumeFile != "" {
+		content, err := ioutil.ReadFile(resumeFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading resume file: %s", err.Error())
+		}
+
+		if err := json.Unmarshal(content, &state); err != nil {
+			return nil, fmt.Errorf("Error reading resume file: %s", err.Error())
+		}
+	}
+
+	result, err := client.CreateChunked(input, hasteServer, hasteServer.URL, chunkSize, state, ioutil.Discard)
+
+	var chunkErr *client.ChunkError
+	if !errors.As(err, &chunkErr) {
+		if err == nil && resumeFile != "" {
+			os.Remove(resumeFile)
+		}
+
+		return result, err
+	}
+
+	resumeFile, saveErr := saveChunkState(resumeFile, chunkErr.State)
+	if saveErr != nil {
+		return nil, fmt.Errorf("%s\n%s", err.Error(), saveErr.Error())
+	}
+
+	return nil, fmt.Errorf("%s\nResume the upload with --resume %s", err.Error(), resumeFile)
+}
+
+func saveChunkState(resumeFile string, state client.ChunkState) (string, error) {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return "", fmt.Errorf("Error saving resume file: %s", err.Error())
+	}
+
+	if resumeFile == "" {
+		file, err := ioutil.TempFile("", "haste-resume-*.json")
+		if err != nil {
+			return "", fmt.Errorf("Error saving resume file: %s", err.Error())
+		}
+		file.Close()
+		resumeFile = file.Name()
+	}
+
+	if err := ioutil.WriteFile(resumeFile, content, 0600); err != nil {
+		return "", fmt.Errorf("Error saving resume file: %s", err.Error())
+	}
+
+	return resumeFile, nil
 }
 
 func addSubCommands(rootCmd *cobra.Command) {
 	rootCmd.AddCommand(NewGetCommand())
+	rootCmd.AddCommand(NewHistoryCommand())
+	rootCmd.AddCommand(NewLastCommand())
+	rootCmd.AddCommand(NewCacheCommand())
+	rootCmd.AddCommand(NewAuditCommand())
+	rootCmd.AddCommand(NewExtractCommand())
+	rootCmd.AddCommand(NewEditCommand())
+	rootCmd.AddCommand(NewDiffCommand())
+	rootCmd.AddCommand(NewRunCommand())
+	rootCmd.AddCommand(NewViewCommand())
 }
 
-// initConfig reads in config file and ENV variables if set.
+// initConfig reads in the organization policy, config file and ENV variables if set.
 func initConfig() {
+	var err error
+	orgPolicy, err = policy.Load(policyFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	orgPolicy.Apply(viper.GetViper())
+
 	if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
@@ -126,4 +523,9 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err != nil {
 		// no config file, no problem
 	}
+
+	if err := orgPolicy.Check(viper.GetViper()); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
 }
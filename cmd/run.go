@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jagoe/haste-client-go/client"
+	"github.com/jagoe/haste-client-go/server"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// reportReserve is the space that is kept free in a report for the parts that are only known after the command has run
+const reportReserve = 256
+
+// NewRunCommand creates a command that runs a command and uploads a report of it
+func NewRunCommand() *cobra.Command {
+	runCmd := &cobra.Command{
+		Use:   "run -- [command] [args...]",
+		Short: "Run a command and upload a report of its output",
+		Long: `Run a command, stream its output to the terminal and upload a report with the command line, exit status,
+	duration and combined STDOUT and STDERR afterwards. Environment variables are only included if they match one of the
+	--env-allow patterns. Output beyond the maximum haste size is left out in the middle. haste exits with the exit
+	status of the command, even if the report cannot be uploaded.`,
+		Example: `haste run -- make test
+	haste run --env-allow 'GO*,PATH' -- go test ./...`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			hasteServer := server.MakeHasteServer()
+			viper.Unmarshal(&hasteServer)
+
+			if err := orgPolicy.CheckCreate(hasteServer.URL); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			printer, err := newPrinter(cmd.OutOrStdout())
+			if err == nil {
+				_, err = resultText(&client.Result{})
+			}
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			patterns, _ := cmd.Flags().GetStringSlice("env-allow")
+			env, err := client.FilterEnv(os.Environ(), patterns)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+
+			report, err := runCommand(cmd, args, env, hasteServer.MaxSize)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(127)
+			}
+
+			result, err := uploadInput(cmd, bytes.NewReader(report.Bytes()), "", hasteServer)
+			if err == nil {
+				err = publish(cmd, cmd.OutOrStdout(), printer, result, client.QuoteCommand(args), "")
+			}
+			if err != nil {
+				// the outcome of the command matters more to scripts than the report
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+			}
+
+			if report.ExitCode != 0 {
+				os.Exit(report.ExitCode)
+			}
+		},
+	}
+
+	// flags after the command belong to the command, even without --
+	runCmd.Flags().SetInterspersed(false)
+	runCmd.Flags().StringSlice("env-allow", nil, "Environment variables to include in the report, e.g. 'GO*,PATH'")
+
+	return runCmd
+}
+
+// runCommand runs a command, passes its output through and captures as much of it as fits into a report of maxSize bytes
+// STDOUT and STDERR of the command share a single pipe, so they are captured in the order they have been written.
+func runCommand(cmd *cobra.Command, args []string, env []string, maxSize int) (client.Report, error) {
+	report := client.Report{Command: args, Env: env, Started: time.Now()}
+	report.Dir, _ = os.Getwd()
+
+	// status, duration and the marker of omitted output are not known yet
+	output := &client.OutputBuffer{Limit: maxSize - len(report.Bytes()) - reportReserve}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return report, fmt.Errorf("Error running command: %s", err.Error())
+	}
+	defer reader.Close()
+
+	child := exec.Command(args[0], args[1:]...)
+	child.Stdin = cmd.InOrStdin()
+	child.Stdout = writer
+	child.Stderr = writer
+
+	// Ctrl+C is meant for the command; haste still has to upload what it has captured
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	err = child.Start()
+	writer.Close()
+	if err != nil {
+		return report, fmt.Errorf("Error running command: %s", err.Error())
+	}
+
+	// the output is captured completely even if the terminal cannot be written to anymore
+	io.Copy(output, client.Tee(reader, cmd.OutOrStdout()))
+	report.Output = output.Bytes()
+	err = child.Wait()
+	report.Duration = time.Since(report.Started)
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return report, fmt.Errorf("Error running command: %s", err.Error())
+	}
+
+	report.Status = child.ProcessState.String()
+	report.ExitCode = child.ProcessState.ExitCode()
+	if status, ok := child.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		// like in a shell, commands that have been terminated by a signal fail with 128+signal
+		report.ExitCode = 128 + int(status.Signal())
+	}
+
+	return report, nil
+}
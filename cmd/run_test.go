@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command requires a POSIX shell")
+	}
+
+	os.Setenv("HASTE_RUN_TEST", "visible")
+	defer os.Unsetenv("HASTE_RUN_TEST")
+
+	output, err := execute(t, "-s", testServer.URL, "--url", "raw", "run", "--env-allow", "HASTE_RUN_*", "--",
+		"sh", "-c", "echo out; echo err >&2; echo out again")
+	if err != nil {
+		t.Fatalf(`Error running command: %s`, err.Error())
+	}
+
+	if !strings.HasPrefix(output, "out\nerr\nout again\n") {
+		t.Fatalf(`Expected the output of the command to be passed through, got "%s"`, output)
+	}
+
+	url := strings.TrimPrefix(output, "out\nerr\nout again\n")
+	report, err := get(url, t)
+	if err != nil {
+		t.Fatalf(`Error reading report: %s`, err.Error())
+	}
+
+	for _, expected := range []string{
+		"$ sh -c 'echo out; echo err >&2; echo out again'\n",
+		"Status:    exit status 0\n",
+		"  HASTE_RUN_TEST=visible\n",
+		"\nout\nerr\nout again\n",
+	} {
+		if !strings.Contains(report, expected) {
+			t.Errorf(`Expected report to contain "%s", got "%s"`, expected, report)
+		}
+	}
+	if strings.Contains(report, "PATH=") {
+		t.Errorf(`Expected only allowed environment variables in the report, got "%s"`, report)
+	}
+}
+
+func TestRunWithLargeOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command requires a POSIX shell")
+	}
+
+	output, err := execute(t, "-s", testServer.URL, "--url", "raw", "--max-size", "2000", "run", "--",
+		"sh", "-c", "i=1; while [ $i -le 2000 ]; do echo line $i; i=$((i+1)); done")
+	if err != nil {
+		t.Fatalf(`Error running command: %s`, err.Error())
+	}
+
+	report, err := get(output[strings.LastIndex(output, "\n")+1:], t)
+	if err != nil {
+		t.Fatalf(`Error reading report: %s`, err.Error())
+	}
+
+	if len(report) > 2000 || !strings.Contains(report, "\nline 1\n") || !strings.HasSuffix(report, "\nline 2000\n") ||
+		!strings.Contains(report, " bytes omitted ...]\n") {
+		t.Errorf(`Expected the report to keep the beginning and the end of the output within 2000 bytes, got "%s"`, report)
+	}
+}
+
+func TestRunWithFailedUpload(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command requires a POSIX shell")
+	}
+
+	// the upload fails, but the command has succeeded, so haste must not exit with an error
+	output, err := execute(t, "-s", "http://127.0.0.1:1", "run", "--", "sh", "-c", "echo done")
+	if err != nil || output != "done\n" {
+		t.Errorf(`Expected only the output of the command, got "%s" (%v)`, output, err)
+	}
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/jagoe/haste-client-go/client"
+	"github.com/jagoe/haste-client-go/scan"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// scanSecrets applies the configured secrets policy to the input before it is uploaded
+// The returned function has to be called with the result of the upload; it cleans up, reports redactions and replaces
+// the upload error if the upload has been blocked.
+func scanSecrets(cmd *cobra.Command, input io.Reader) (io.Reader, func(error) error, error) {
+	scanner, policy, err := secretScanner()
+	if err != nil || policy == scan.PolicyOff {
+		return input, func(err error) error { return err }, err
+	}
+
+	if policy == scan.PolicyRedact {
+		filter := scanner.Filter(input, policy)
+		return filter, func(err error) error {
+			reportRedactions(cmd, filter.Findings)
+			return err
+		}, nil
+	}
+
+	// the whole input is scanned before anything is uploaded, so no part of blocked content reaches the server
+	spool, findings, err := scanner.Spool(input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cleanup := func(err error) error {
+		spool.Close()
+		os.Remove(spool.Name())
+		return err
+	}
+
+	if policy == scan.PolicyBlock && len(findings) > 0 {
+		return nil, nil, cleanup(&scan.FoundError{Findings: findings})
+	}
+
+	if err := confirmSecrets(cmd, findings); err != nil {
+		return nil, nil, cleanup(err)
+	}
+
+	return spool, cleanup, nil
+}
+
+// scanFiles applies the configured secrets policy to files before they are packed or uploaded separately
+// Binary files are not scanned.
+func scanFiles(cmd *cobra.Command, files []client.BundleFile) ([]client.BundleFile, error) {
+	scanner, policy, err := secretScanner()
+	if err != nil || policy == scan.PolicyOff {
+		return files, err
+	}
+
+	var findings []scan.Finding
+	scanned := make([]client.BundleFile, len(files))
+	for i, file := range files {
+		scanned[i] = file
+		if file.Encoding != "" {
+			continue
+		}
+
+		filter := scanner.Filter(bytes.NewReader(file.Content), policy)
+		content, err := ioutil.ReadAll(filter)
+		for _, finding := range filter.Findings {
+			finding.Rule = fmt.Sprintf("%s in %s", finding.Rule, file.Path)
+			findings = append(findings, finding)
+		}
+
+		if foundErr, ok := err.(*scan.FoundError); ok {
+			foundErr.Findings = findings
+			return nil, foundErr
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if policy == scan.PolicyRedact && len(filter.Findings) > 0 {
+			scanned[i], _ = client.MakeBundleFile(file.Path, content, client.BinaryEncode)
+		}
+	}
+
+	if policy == scan.PolicyRedact {
+		reportRedactions(cmd, findings)
+		return scanned, nil
+	}
+
+	return scanned, confirmSecrets(cmd, findings)
+}
+
+func secretScanner() (*scan.Scanner, scan.Policy, error) {
+	policy, err := scan.ParsePolicy(viper.GetString("secrets.policy"))
+	if err != nil || policy == scan.PolicyOff {
+		return nil, policy, err
+	}
+	decide("secrets policy %s", policy)
+
+	var rules []scan.Rule
+	if err := viper.UnmarshalKey("secrets.rules", &rules); err != nil {
+		return nil, policy, fmt.Errorf("Invalid secret rules: %s", err.Error())
+	}
+
+	scanner, err := scan.NewScanner(rules, viper.GetStringSlice("secrets.allowlist"))
+	return scanner, policy, err
+}
+
+// confirmSecrets lists possible secrets and asks whether they should be uploaded anyway
+func confirmSecrets(cmd *cobra.Command, findings []scan.Finding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(cmd.ErrOrStderr(), "Possible secrets found:")
+	for _, finding := range findings {
+		fmt.Fprintf(cmd.ErrOrStderr(), "  %s\n", finding)
+	}
+
+	if !confirm(cmd, "Upload anyway?") {
+		return fmt.Errorf("Upload aborted; use --yes to upload anyway or --secrets=redact to redact possible secrets")
+	}
+
+	decide("%d possible secrets confirmed", len(findings))
+	return nil
+}
+
+func reportRedactions(cmd *cobra.Command, findings []scan.Finding) {
+	if len(findings) > 0 {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Redacted %d possible secrets\n", len(findings))
+		decide("%d possible secrets redacted", len(findings))
+	}
+}
+
+// confirm asks a yes/no question on the terminal, since STDIN may be the content that is being uploaded
+func confirm(cmd *cobra.Command, question string) bool {
+	if viper.GetBool("yes") {
+		return true
+	}
+
+	terminal := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		terminal = "CONIN$"
+	}
+
+	tty, err := os.Open(terminal)
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
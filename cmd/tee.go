@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jagoe/haste-client-go/client"
+	"github.com/spf13/cobra"
+)
+
+// teeInput passes STDIN through to STDOUT while it is uploaded
+// Interrupting the producer with Ctrl+C interrupts haste as well, so the first interrupt only ends the input and the
+// captured output is still uploaded; a second one aborts. A closed STDOUT, e.g. piping into head, is not fatal either.
+// The returned function has to be called after the upload.
+func teeInput(cmd *cobra.Command) (io.Reader, func()) {
+	tee := client.Tee(cmd.InOrStdin(), cmd.OutOrStdout())
+
+	// without a handler, writing to a closed pipe on STDOUT terminates the program with SIGPIPE
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGPIPE)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig == os.Interrupt {
+					fmt.Fprintln(cmd.ErrOrStderr(), "Interrupted; uploading the captured output (press Ctrl+C again to abort)")
+					tee.Interrupt()
+					signal.Reset(os.Interrupt)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return tee, func() {
+		signal.Stop(signals)
+		close(done)
+
+		if tee.Err != nil && !errors.Is(tee.Err, syscall.EPIPE) {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: Error passing input through: %s\n", tee.Err.Error())
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/jagoe/haste-client-go/client"
+	"github.com/jagoe/haste-client-go/util"
+	"github.com/jagoe/haste-client-go/viewer"
+	"github.com/spf13/cobra"
+)
+
+// NewViewCommand creates a command that shows a haste in a browser
+func NewViewCommand() *cobra.Command {
+	viewCmd := &cobra.Command{
+		Use:   "view [haste key or URL]",
+		Short: "View a haste in a browser",
+		Long: `Print the URL of a haste in the web UI of its server or, with --serve, fetch it and serve it in a viewer on
+	localhost. Binary envelopes and chunked hastes are decoded locally, so their content is not sent to anyone else. The
+	viewer highlights the haste, links every line (#L10-L40) and offers the raw haste for download; it stops after
+	--timeout or on Ctrl+C.`,
+		Example: `haste view oyivuxonema
+	haste view oyivuxonema#L10-L40 --serve
+	haste view http://pastebin.com/oyivuxonema --serve --port 8080 --timeout 1h`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			server, key := resolveRef(args[0])
+			_, anchor := util.SplitAnchor(args[0])
+			if anchor != "" {
+				anchor = "#" + anchor
+			}
+
+			if serve, _ := cmd.Flags().GetBool("serve"); !serve {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s/%s%s\n", server.URL, key, anchor)
+				return
+			}
+
+			document, err := client.Fetch(key, cachedGetter(server))
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+			recordGet(cmd, server.URL, document, "")
+
+			if err := serveDocument(cmd, document, fmt.Sprintf("%s/%s", server.URL, key), anchor); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	initViewCommand(viewCmd)
+
+	return viewCmd
+}
+
+func initViewCommand(cmd *cobra.Command) {
+	cmd.Flags().Bool("serve", false, "Serve the haste in a viewer on localhost")
+	cmd.Flags().Int("port", 0, "Port of the viewer; a free port is chosen by default")
+	cmd.Flags().Duration("timeout", 10*time.Minute, "Time after which the viewer stops")
+}
+
+// serveDocument serves a fetched haste on localhost and prints the URL of the viewer, including an anchor like #L10-L40
+func serveDocument(cmd *cobra.Command, document *client.Document, title string, anchor string) error {
+	port, _ := cmd.Flags().GetInt("port")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	extension := ""
+	if document.Envelope == nil {
+		extension = documentExtension(document)
+	}
+	page, err := viewer.New(document, title, extension)
+	if err != nil {
+		return err
+	}
+
+	// only local processes can reach the viewer
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return fmt.Errorf("Error starting viewer: %s", err.Error())
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "http://%s%s%s\n", listener.Addr().String(), page.Path(), anchor)
+	fmt.Fprintf(cmd.ErrOrStderr(), "Serving the haste for %s, press Ctrl+C to stop\n", timeout)
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	stop := make(chan struct{})
+	go func() {
+		if _, ok := <-interrupts; ok {
+			close(stop)
+		}
+	}()
+
+	return viewer.Serve(listener, page, timeout, stop)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestView(t *testing.T) {
+	url, err := create("View test\n", t, "--url", "raw")
+	if err != nil {
+		t.Fatalf(`Error creating haste: %s`, err.Error())
+	}
+	viewURL := strings.Replace(url, "/raw/", "/", 1)
+
+	t.Run("should print the URL of the haste", func(t *testing.T) {
+		output, err := execute(t, "view", url+"#L2")
+		if err != nil {
+			t.Fatalf(`Error viewing haste: %s`, err.Error())
+		}
+
+		if output != viewURL+"#L2\n" {
+			t.Errorf(`Expected "%s#L2", got "%s"`, viewURL, output)
+		}
+	})
+
+	t.Run("should serve the haste on localhost", func(t *testing.T) {
+		reader, writer := io.Pipe()
+		cmd := NewRootCommand()
+		cmd.SetArgs([]string{"view", url, "--serve", "--timeout", "1s"})
+		cmd.SetOut(writer)
+		cmd.SetErr(ioutil.Discard)
+
+		done := make(chan error)
+		go func() {
+			err := cmd.Execute()
+			writer.Close()
+			done <- err
+		}()
+
+		pageURL, err := bufio.NewReader(reader).ReadString('\n')
+		if err != nil || !strings.HasPrefix(pageURL, "http://127.0.0.1:") {
+			t.Fatalf(`Expected the URL of the viewer, got "%s"`, pageURL)
+		}
+
+		response, err := http.Get(strings.TrimSpace(pageURL) + "raw")
+		if err != nil {
+			t.Fatalf(`Error requesting haste: %s`, err.Error())
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+
+		if string(body) != "View test\n" {
+			t.Errorf(`Expected "View test", got "%s"`, body)
+		}
+		if err := <-done; err != nil {
+			t.Errorf(`Error serving haste: %s`, err.Error())
+		}
+	})
+}
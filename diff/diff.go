@@ -0,0 +1,441 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Kind is the kind of an edit
+type Kind int
+
+const (
+	// Equal marks text that both sides share
+	Equal Kind = iota
+	// Delete marks text that only the old side contains
+	Delete
+	// Insert marks text that only the new side contains
+	Insert
+)
+
+// DefaultContext is the number of unchanged lines around every change
+const DefaultContext = 3
+
+// Edit is a single line or word of a diff
+type Edit struct {
+	Kind Kind
+	Text string
+}
+
+// Options configure how a unified diff is formatted
+type Options struct {
+	// Context is the number of unchanged lines around every change
+	Context int
+	// Color highlights deletions and insertions with ANSI colors
+	Color bool
+	// Words marks changed words within lines instead of changed lines
+	Words bool
+}
+
+// Stat summarizes a diff
+type Stat struct {
+	Insertions int
+	Deletions  int
+}
+
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorCyan   = "\x1b[36m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	noNewline   = "\\ No newline at end of file"
+	hunkPattern = "@@ -%s +%s @@"
+)
+
+var wordPattern = regexp.MustCompile(`\s+|\w+|[^\w\s]`)
+
+// Lines computes the line edits that turn the old text into the new text
+func Lines(oldText, newText string) []Edit {
+	return Compute(SplitLines(oldText), SplitLines(newText))
+}
+
+// SplitLines splits text into lines that keep their line feed, so a missing line feed at the end is a change as well
+func SplitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// Compute determines the shortest edit script that turns the old tokens into the new tokens (Myers' algorithm)
+// The linear space variant is used, so memory grows with the number of tokens rather than with their product.
+func Compute(old, new []string) []Edit {
+	return appendEdits(nil, old, new)
+}
+
+// Unified formats line edits as a unified diff; an empty string means there are no differences
+func Unified(edits []Edit, oldName, newName string, options Options) string {
+	hunks := hunks(edits, options.Context)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	out.WriteString(paint(fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName), colorBold, options.Color))
+	for _, hunk := range hunks {
+		header := fmt.Sprintf(hunkPattern, hunkRange(hunk.oldStart, hunk.oldLines), hunkRange(hunk.newStart, hunk.newLines))
+		out.WriteString(paint(header, colorCyan, options.Color) + "\n")
+
+		if options.Words {
+			writeWords(&out, hunk.edits, options.Color)
+			continue
+		}
+
+		for _, edit := range hunk.edits {
+			switch edit.Kind {
+			case Equal:
+				writeLine(&out, " ", edit.Text, "", false)
+			case Delete:
+				writeLine(&out, "-", edit.Text, colorRed, options.Color)
+			case Insert:
+				writeLine(&out, "+", edit.Text, colorGreen, options.Color)
+			}
+		}
+	}
+
+	return out.String()
+}
+
+// Summarize counts the deleted and inserted lines
+func Summarize(edits []Edit) Stat {
+	var stat Stat
+	for _, edit := range edits {
+		switch edit.Kind {
+		case Delete:
+			stat.Deletions++
+		case Insert:
+			stat.Insertions++
+		}
+	}
+
+	return stat
+}
+
+// Format prints the stat like git diff --stat
+func (stat Stat) Format(name string, color bool) string {
+	changes := stat.Insertions + stat.Deletions
+	if changes == 0 {
+		return ""
+	}
+
+	// the bar is scaled down to at most 50 characters
+	insertions, deletions := stat.Insertions, stat.Deletions
+	if changes > 50 {
+		insertions = stat.Insertions * 50 / changes
+		deletions = stat.Deletions * 50 / changes
+	}
+
+	return fmt.Sprintf(" %s | %d %s%s\n 1 file changed, %d insertions(+), %d deletions(-)\n", name, changes,
+		paint(strings.Repeat("+", insertions), colorGreen, color), paint(strings.Repeat("-", deletions), colorRed, color),
+		stat.Insertions, stat.Deletions)
+}
+
+// #region Private
+
+type hunk struct {
+	oldStart, oldLines int
+	newStart, newLines int
+	edits              []Edit
+}
+
+// appendEdits appends the edits of two sides by splitting them at the middle of a shortest edit script and solving both
+// halves until only insertions or deletions are left
+func appendEdits(edits []Edit, old, new []string) []Edit {
+	// a common prefix and suffix do not need to be searched
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix &&
+		old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+
+	for _, text := range old[:prefix] {
+		edits = append(edits, Edit{Equal, text})
+	}
+
+	changedOld, changedNew := old[prefix:len(old)-suffix], new[prefix:len(new)-suffix]
+	x, y, ok := middle(changedOld, changedNew)
+	if ok {
+		edits = appendEdits(edits, changedOld[:x], changedNew[:y])
+		edits = appendEdits(edits, changedOld[x:], changedNew[y:])
+	} else {
+		for _, text := range changedOld {
+			edits = append(edits, Edit{Delete, text})
+		}
+		for _, text := range changedNew {
+			edits = append(edits, Edit{Insert, text})
+		}
+	}
+
+	for _, text := range old[len(old)-suffix:] {
+		edits = append(edits, Edit{Equal, text})
+	}
+
+	return edits
+}
+
+// middle finds a point on a shortest edit script that splits it into two halves by searching forward from the start and
+// backward from the end at the same time until the paths overlap
+// It returns false if one side is empty, so there is nothing to split.
+func middle(old, new []string) (int, int, bool) {
+	n, m := len(old), len(new)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+
+	max := (n + m + 1) / 2
+	offset := max + 1
+	forward := make([]int, 2*max+3)
+	backward := make([]int, 2*max+3)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	delta := n - m
+	// with an odd delta the forward search reaches the overlap first, otherwise the backward search does
+	odd := delta%2 != 0
+	// diagonals that have left the grid are not followed anymore
+	forwardStart, forwardEnd, backwardStart, backwardEnd := 0, 0, 0, 0
+
+	for d := 0; d < max; d++ {
+		for k := -d + forwardStart; k <= d-forwardEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && old[x] == new[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			switch {
+			case x > n:
+				forwardEnd += 2
+			case y > m:
+				forwardStart += 2
+			case odd:
+				reverse := offset + delta - k
+				if reverse >= 0 && reverse < len(backward) && backward[reverse] != -1 && x >= n-backward[reverse] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + backwardStart; k <= d-backwardEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && old[n-1-x] == new[m-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			switch {
+			case x > n:
+				backwardEnd += 2
+			case y > m:
+				backwardStart += 2
+			case !odd:
+				reverse := offset + delta - k
+				if reverse >= 0 && reverse < len(forward) && forward[reverse] != -1 && forward[reverse] >= n-x {
+					forwardX := forward[reverse]
+					return forwardX, forwardX - (delta - k), true
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// hunks groups changes that are at most twice the context apart together with their surrounding lines
+func hunks(edits []Edit, context int) []hunk {
+	if context < 0 {
+		context = 0
+	}
+
+	var result []hunk
+	oldLine, newLine := 1, 1
+	start := -1
+	lastChange := -1
+	var current hunk
+
+	flush := func(end int) {
+		current.edits = edits[start:end]
+		for _, edit := range current.edits {
+			if edit.Kind != Insert {
+				current.oldLines++
+			}
+			if edit.Kind != Delete {
+				current.newLines++
+			}
+		}
+		result = append(result, current)
+		start = -1
+	}
+
+	for i, edit := range edits {
+		if edit.Kind != Equal {
+			if start >= 0 && i-lastChange > 2*context+1 {
+				flush(lastChange + context + 1)
+			}
+			if start < 0 {
+				start = i - context
+				if start < 0 {
+					start = 0
+				}
+				current = hunk{oldStart: oldLine, newStart: newLine}
+				for _, before := range edits[start:i] {
+					if before.Kind == Equal {
+						current.oldStart--
+						current.newStart--
+					}
+				}
+			}
+			lastChange = i
+		}
+
+		if edit.Kind != Insert {
+			oldLine++
+		}
+		if edit.Kind != Delete {
+			newLine++
+		}
+	}
+
+	if start >= 0 {
+		end := lastChange + context + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+		flush(end)
+	}
+
+	return result
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 0 {
+		// an empty range refers to the line before the change
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+func writeLine(out *strings.Builder, prefix, text, color string, useColor bool) {
+	line := strings.TrimSuffix(text, "\n")
+	out.WriteString(paint(prefix+line, color, useColor) + "\n")
+	if !strings.HasSuffix(text, "\n") {
+		out.WriteString(noNewline + "\n")
+	}
+}
+
+// writeWords compares the deleted and inserted lines of every change word by word and marks the changed words
+// Without colors, deletions are marked as [-word-] and insertions as {+word+} like git diff --word-diff.
+func writeWords(out *strings.Builder, edits []Edit, color bool) {
+	var deleted, inserted strings.Builder
+	flush := func() {
+		if deleted.Len() == 0 && inserted.Len() == 0 {
+			return
+		}
+
+		words := Compute(wordPattern.FindAllString(deleted.String(), -1), wordPattern.FindAllString(inserted.String(), -1))
+		var line strings.Builder
+		for _, word := range words {
+			switch word.Kind {
+			case Equal:
+				line.WriteString(word.Text)
+			case Delete:
+				line.WriteString(markWord(word.Text, "[-", "-]", colorRed, color))
+			case Insert:
+				line.WriteString(markWord(word.Text, "{+", "+}", colorGreen, color))
+			}
+		}
+
+		text := line.String()
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		out.WriteString(text)
+		deleted.Reset()
+		inserted.Reset()
+	}
+
+	for _, edit := range edits {
+		switch edit.Kind {
+		case Delete:
+			deleted.WriteString(edit.Text)
+		case Insert:
+			inserted.WriteString(edit.Text)
+		default:
+			flush()
+			out.WriteString(edit.Text)
+			if !strings.HasSuffix(edit.Text, "\n") {
+				out.WriteString("\n")
+			}
+		}
+	}
+	flush()
+}
+
+// markWord marks a changed word; line feeds stay outside of the markers, so every line is marked separately
+func markWord(word, open, close, color string, useColor bool) string {
+	if strings.TrimSpace(word) == "" && strings.Contains(word, "\n") {
+		return word
+	}
+	if useColor {
+		return paint(word, color, true)
+	}
+
+	return open + word + close
+}
+
+func paint(text, color string, useColor bool) string {
+	if !useColor || text == "" || color == "" {
+		return text
+	}
+
+	// colors are reset before line feeds, so they do not bleed into the next line
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		content := strings.TrimSuffix(line, "\n")
+		lines[i] = color + content + colorReset + line[len(content):]
+	}
+
+	return strings.Join(lines, "")
+}
+
+// #endregion
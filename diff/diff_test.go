@@ -0,0 +1,133 @@
+package diff
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	t.Run("should find the shortest edit script", func(t *testing.T) {
+		edits := Compute(strings.Split("ABCABBA", ""), strings.Split("CBABAC", ""))
+
+		changes := 0
+		var old, new strings.Builder
+		for _, edit := range edits {
+			if edit.Kind != Equal {
+				changes++
+			}
+			if edit.Kind != Insert {
+				old.WriteString(edit.Text)
+			}
+			if edit.Kind != Delete {
+				new.WriteString(edit.Text)
+			}
+		}
+
+		if old.String() != "ABCABBA" || new.String() != "CBABAC" {
+			t.Errorf(`Expected edits to reproduce both sides, got "%s" and "%s"`, old.String(), new.String())
+		}
+		if changes != 5 {
+			t.Errorf(`Expected 5 changes, got %d`, changes)
+		}
+	})
+
+	t.Run("should compare large inputs in linear space", func(t *testing.T) {
+		old, new := make([]string, 4000), make([]string, 4000)
+		for i := range old {
+			old[i] = fmt.Sprintf("old %d\n", i)
+			new[i] = fmt.Sprintf("new %d\n", i)
+		}
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		stat := Summarize(Compute(old, new))
+		runtime.ReadMemStats(&after)
+
+		if stat.Insertions != 4000 || stat.Deletions != 4000 {
+			t.Errorf(`Expected 4000 insertions and deletions, got %+v`, stat)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+			t.Errorf(`Expected less than 64MB to be allocated, got %dMB`, allocated>>20)
+		}
+	})
+
+	t.Run("should treat a missing line feed at the end as a change", func(t *testing.T) {
+		stat := Summarize(Lines("a\nb\n", "a\nb"))
+		if stat.Insertions != 1 || stat.Deletions != 1 {
+			t.Errorf(`Expected 1 insertion and 1 deletion, got %+v`, stat)
+		}
+	})
+}
+
+func TestUnified(t *testing.T) {
+	t.Run("should format changes with context as hunks", func(t *testing.T) {
+		oldText := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+		newText := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+
+		expected := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`
+		if unified := Unified(Lines(oldText, newText), "a", "b", Options{Context: DefaultContext}); unified != expected {
+			t.Errorf("Expected\n%s\ngot\n%s", expected, unified)
+		}
+	})
+
+	t.Run("should treat a negative context as no context", func(t *testing.T) {
+		unified := Unified(Lines("a\nb\nc\n", "a\nB\nc\n"), "a", "b", Options{Context: -1})
+		if unified != "--- a\n+++ b\n@@ -2 +2 @@\n-b\n+B\n" {
+			t.Errorf(`Expected a hunk without context, got "%s"`, unified)
+		}
+	})
+
+	t.Run("should return nothing for equal texts", func(t *testing.T) {
+		if unified := Unified(Lines("a\n", "a\n"), "a", "b", Options{Context: DefaultContext}); unified != "" {
+			t.Errorf(`Expected no diff, got "%s"`, unified)
+		}
+	})
+
+	t.Run("should mark a missing line feed at the end", func(t *testing.T) {
+		unified := Unified(Lines("a\n", "a\nb"), "a", "b", Options{})
+		if !strings.HasSuffix(unified, "+b\n\\ No newline at end of file\n") {
+			t.Errorf(`Expected missing line feed to be marked, got "%s"`, unified)
+		}
+	})
+
+	t.Run("should mark changed words", func(t *testing.T) {
+		unified := Unified(Lines("port: 80\nhost: a\n", "port: 8080\nhost: a\n"), "a", "b", Options{Context: DefaultContext, Words: true})
+		if !strings.Contains(unified, "port: [-80-]{+8080+}\nhost: a\n") {
+			t.Errorf(`Expected changed words to be marked, got "%s"`, unified)
+		}
+	})
+
+	t.Run("should color changes", func(t *testing.T) {
+		unified := Unified(Lines("a\n", "b\n"), "a", "b", Options{Color: true})
+		if !strings.Contains(unified, colorRed+"-a"+colorReset+"\n") || !strings.Contains(unified, colorGreen+"+b"+colorReset+"\n") {
+			t.Errorf(`Expected changes to be colored, got %q`, unified)
+		}
+	})
+}
+
+func TestStat(t *testing.T) {
+	t.Run("should summarize changes", func(t *testing.T) {
+		stat := Summarize(Lines("a\nb\nc\n", "a\nB\nc\nd\n"))
+		expected := " a => b | 3 ++-\n 1 file changed, 2 insertions(+), 1 deletions(-)\n"
+		if formatted := stat.Format("a => b", false); formatted != expected {
+			t.Errorf(`Expected "%s", got "%s"`, expected, formatted)
+		}
+	})
+}
@@ -0,0 +1,231 @@
+package haste
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/jagoe/haste-client-go/client"
+	"github.com/jagoe/haste-client-go/server"
+)
+
+// DefaultMemoryLimit is the number of bytes a Writer keeps in memory before it spools them to a temporary file
+const DefaultMemoryLimit = 1 << 20
+
+// ErrClosed is returned by writes after a Writer has been closed or aborted
+var ErrClosed = errors.New("Error writing haste: the writer has been closed")
+
+// TooLargeError is returned if more content has been written than the limit of a Writer allows
+type TooLargeError struct {
+	Limit int64
+}
+
+func (err *TooLargeError) Error() string {
+	return fmt.Sprintf("Error writing haste: the content exceeds the limit of %d bytes", err.Limit)
+}
+
+// Writer collects content and uploads it as a haste when it is closed
+// Content is kept in memory up to MemoryLimit bytes and spooled to a temporary file beyond that. Writes are safe for
+// concurrent use; every single write ends up in the haste as a whole.
+//
+//	w := haste.NewWriter(hasteServer)
+//	fmt.Fprintf(w, "report of %s\n", name)
+//	if err := w.Close(); err == nil {
+//		fmt.Println(w.URL())
+//	}
+type Writer struct {
+	// Chunked splits content that exceeds the maximum haste size of the server into several hastes that are listed by a
+	// manifest haste; otherwise writes beyond the maximum haste size fail with a TooLargeError
+	Chunked bool
+	// ChunkSize is the maximum size of a single chunk; 0 means the maximum haste size of the server
+	ChunkSize int
+	// Limit is the maximum number of bytes the writer accepts; 0 means the maximum haste size of the server or no limit
+	// if Chunked is set
+	Limit int64
+	// MemoryLimit is the number of bytes kept in memory before the content is spooled to a temporary file
+	MemoryLimit int
+
+	creator   server.HasteCreator
+	serverURL string
+	maxSize   int
+
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+	file   *os.File
+	size   int64
+	closed bool
+	result *client.Result
+	err    error
+}
+
+// NewWriter creates a writer that uploads to a haste-server instance created by server.MakeHasteServer
+func NewWriter(hasteServer server.HasteServer) *Writer {
+	return &Writer{
+		MemoryLimit: DefaultMemoryLimit,
+		creator:     hasteServer,
+		serverURL:   hasteServer.URL,
+		maxSize:     hasteServer.MaxSize,
+	}
+}
+
+// Write adds content to the haste
+// Once a write has failed, every following write and Close fail with the same error.
+func (writer *Writer) Write(content []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return 0, ErrClosed
+	}
+	if writer.err != nil {
+		return 0, writer.err
+	}
+
+	if limit := writer.limit(); limit > 0 && writer.size+int64(len(content)) > limit {
+		writer.err = &TooLargeError{Limit: limit}
+		return 0, writer.err
+	}
+
+	if writer.file == nil && writer.buffer.Len()+len(content) > writer.MemoryLimit {
+		if err := writer.spool(); err != nil {
+			writer.err = err
+			return 0, err
+		}
+	}
+
+	var written int
+	var err error
+	if writer.file != nil {
+		written, err = writer.file.Write(content)
+	} else {
+		written, err = writer.buffer.Write(content)
+	}
+	writer.size += int64(written)
+	if err != nil {
+		writer.err = fmt.Errorf("Error writing haste: %s", err.Error())
+		return written, writer.err
+	}
+
+	return written, nil
+}
+
+// Close uploads the content as a haste; its URL is available from URL and Result afterwards
+// Closing the writer again returns the outcome of the first call.
+func (writer *Writer) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return writer.err
+	}
+	writer.closed = true
+	defer writer.discard()
+
+	if writer.err != nil {
+		return writer.err
+	}
+
+	var input io.Reader = &writer.buffer
+	if writer.file != nil {
+		if _, err := writer.file.Seek(0, io.SeekStart); err != nil {
+			writer.err = fmt.Errorf("Error reading haste: %s", err.Error())
+			return writer.err
+		}
+		input = writer.file
+	}
+
+	writer.result, writer.err = writer.upload(input)
+	return writer.err
+}
+
+// Abort discards the content without uploading it
+func (writer *Writer) Abort() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if !writer.closed {
+		writer.closed = true
+		writer.err = ErrClosed
+		writer.discard()
+	}
+}
+
+// Result describes the uploaded haste; it is nil until Close has succeeded
+func (writer *Writer) Result() *client.Result {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	return writer.result
+}
+
+// URL returns the URL of the uploaded haste or an empty string until Close has succeeded
+func (writer *Writer) URL() string {
+	if result := writer.Result(); result != nil {
+		return result.URL
+	}
+
+	return ""
+}
+
+// #region Private
+
+func (writer *Writer) limit() int64 {
+	if writer.Limit > 0 || writer.Chunked {
+		return writer.Limit
+	}
+
+	return int64(writer.maxSize)
+}
+
+// spool moves the content that has been written so far from memory to a temporary file
+func (writer *Writer) spool() error {
+	file, err := ioutil.TempFile("", "haste-writer-*")
+	if err != nil {
+		return fmt.Errorf("Error spooling haste: %s", err.Error())
+	}
+
+	if _, err := writer.buffer.WriteTo(file); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return fmt.Errorf("Error spooling haste: %s", err.Error())
+	}
+
+	writer.file = file
+	return nil
+}
+
+func (writer *Writer) upload(input io.Reader) (*client.Result, error) {
+	if !writer.Chunked {
+		return client.Create(input, writer.creator, writer.serverURL, ioutil.Discard)
+	}
+
+	input, exceeds, err := client.ExceedsLimit(input, writer.maxSize)
+	if err != nil {
+		return nil, err
+	}
+	if !exceeds {
+		return client.Create(input, writer.creator, writer.serverURL, ioutil.Discard)
+	}
+
+	chunkSize := writer.ChunkSize
+	if chunkSize <= 0 || chunkSize > writer.maxSize {
+		chunkSize = writer.maxSize
+	}
+
+	return client.CreateChunked(input, writer.creator, writer.serverURL, chunkSize, client.ChunkState{}, ioutil.Discard)
+}
+
+func (writer *Writer) discard() {
+	writer.buffer = bytes.Buffer{}
+	if writer.file != nil {
+		writer.file.Close()
+		os.Remove(writer.file.Name())
+		writer.file = nil
+	}
+}
+
+// #endregion
@@ -0,0 +1,210 @@
+package haste
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jagoe/haste-client-go/client"
+	"github.com/jagoe/haste-client-go/server"
+)
+
+type FakeCreator struct {
+	mutex  sync.Mutex
+	hastes []string
+	err    error
+}
+
+func (fake *FakeCreator) Create(content io.Reader, _ *http.Client) (string, error) {
+	if fake.err != nil {
+		return "", fake.err
+	}
+
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.hastes = append(fake.hastes, string(data))
+
+	return fmt.Sprintf("k%d", len(fake.hastes)), nil
+}
+
+func newTestWriter(creator *FakeCreator, maxSize int) *Writer {
+	hasteServer := server.MakeHasteServer()
+	hasteServer.URL = "https://haste.example"
+	hasteServer.MaxSize = maxSize
+
+	writer := NewWriter(hasteServer)
+	writer.creator = creator
+	return writer
+}
+
+func TestWriter(t *testing.T) {
+	t.Run("should upload the content on Close", func(t *testing.T) {
+		creator := &FakeCreator{}
+		writer := newTestWriter(creator, 100)
+		fmt.Fprintf(writer, "report of %s\n", "test")
+
+		if writer.URL() != "" {
+			t.Error("Expected no URL before Close")
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Error closing writer: %s", err.Error())
+		}
+
+		if writer.URL() != "https://haste.example/k1" {
+			t.Errorf(`Expected URL "https://haste.example/k1", got "%s"`, writer.URL())
+		}
+		if len(creator.hastes) != 1 || creator.hastes[0] != "report of test\n" {
+			t.Errorf("Expected the written content to be uploaded once, got %q", creator.hastes)
+		}
+		if writer.Result().Size != 15 {
+			t.Errorf("Expected a size of 15, got %d", writer.Result().Size)
+		}
+
+		if err := writer.Close(); err != nil || len(creator.hastes) != 1 {
+			t.Error("Expected a second Close not to upload again")
+		}
+		if _, err := writer.Write([]byte("more")); err != ErrClosed {
+			t.Errorf("Expected ErrClosed after Close, got %v", err)
+		}
+	})
+
+	t.Run("should spool large content to a temporary file", func(t *testing.T) {
+		creator := &FakeCreator{}
+		writer := newTestWriter(creator, 100)
+		writer.MemoryLimit = 8
+
+		fmt.Fprint(writer, "0123456")
+		if writer.file != nil {
+			t.Fatal("Expected content below the memory limit to be kept in memory")
+		}
+		fmt.Fprint(writer, "789")
+		if writer.file == nil {
+			t.Fatal("Expected content beyond the memory limit to be spooled")
+		}
+		spool := writer.file.Name()
+
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Error closing writer: %s", err.Error())
+		}
+		if creator.hastes[0] != "0123456789" {
+			t.Errorf(`Expected "0123456789", got "%s"`, creator.hastes[0])
+		}
+		if _, err := os.Stat(spool); !os.IsNotExist(err) {
+			t.Error("Expected the spool file to be removed")
+		}
+	})
+
+	t.Run("should refuse content that exceeds the maximum haste size", func(t *testing.T) {
+		creator := &FakeCreator{}
+		writer := newTestWriter(creator, 10)
+
+		fmt.Fprint(writer, "0123456789")
+		_, err := fmt.Fprint(writer, "!")
+
+		var tooLarge *TooLargeError
+		if !errors.As(err, &tooLarge) || tooLarge.Limit != 10 {
+			t.Fatalf("Expected a TooLargeError with limit 10, got %v", err)
+		}
+		if err := writer.Close(); err != tooLarge {
+			t.Errorf("Expected Close to fail with the write error, got %v", err)
+		}
+		if len(creator.hastes) != 0 {
+			t.Error("Expected nothing to be uploaded")
+		}
+	})
+
+	t.Run("should upload large content in chunks", func(t *testing.T) {
+		creator := &FakeCreator{}
+		writer := newTestWriter(creator, 10)
+		writer.Chunked = true
+		fmt.Fprint(writer, "line 1\nline 2\nline 3\n")
+
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Error closing writer: %s", err.Error())
+		}
+
+		if len(creator.hastes) != 4 {
+			t.Fatalf("Expected 3 chunks and a manifest, got %q", creator.hastes)
+		}
+		manifest, ok := client.ParseManifest(creator.hastes[3])
+		if !ok || len(manifest.Chunks) != 3 || manifest.Size != 21 {
+			t.Errorf("Expected a manifest of 3 chunks, got %s", creator.hastes[3])
+		}
+		if writer.URL() != "https://haste.example/k4" {
+			t.Errorf(`Expected the URL of the manifest, got "%s"`, writer.URL())
+		}
+	})
+
+	t.Run("should honor the limit when uploading in chunks", func(t *testing.T) {
+		writer := newTestWriter(&FakeCreator{}, 10)
+		writer.Chunked = true
+		writer.Limit = 15
+
+		if _, err := fmt.Fprint(writer, "0123456789abcdef"); err == nil {
+			t.Error("Expected content beyond the limit to be refused")
+		}
+	})
+
+	t.Run("should keep concurrent writes intact", func(t *testing.T) {
+		creator := &FakeCreator{}
+		writer := newTestWriter(creator, 100000)
+		writer.MemoryLimit = 1000
+
+		var wait sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wait.Add(1)
+			go func(i int) {
+				defer wait.Done()
+				for j := 0; j < 50; j++ {
+					fmt.Fprintf(writer, "writer %02d line %02d\n", i, j)
+				}
+			}(i)
+		}
+		wait.Wait()
+
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Error closing writer: %s", err.Error())
+		}
+
+		lines := strings.Split(strings.TrimSuffix(creator.hastes[0], "\n"), "\n")
+		sort.Strings(lines)
+		if len(lines) != 1000 || lines[0] != "writer 00 line 00" || lines[999] != "writer 19 line 49" {
+			t.Errorf("Expected 1000 intact lines, got %d", len(lines))
+		}
+	})
+
+	t.Run("should not upload aborted content", func(t *testing.T) {
+		creator := &FakeCreator{}
+		writer := newTestWriter(creator, 100)
+		fmt.Fprint(writer, "secret")
+		writer.Abort()
+
+		if err := writer.Close(); err != ErrClosed || len(creator.hastes) != 0 {
+			t.Errorf("Expected aborted content not to be uploaded, got %v", err)
+		}
+	})
+
+	t.Run("should return upload errors from Close", func(t *testing.T) {
+		writer := newTestWriter(&FakeCreator{err: fmt.Errorf("Error creating haste: offline")}, 100)
+		fmt.Fprint(writer, "content")
+
+		if err := writer.Close(); err == nil || err.Error() != "Error creating haste: offline" {
+			t.Errorf("Expected the upload error, got %v", err)
+		}
+		if writer.URL() != "" {
+			t.Error("Expected no URL after a failed upload")
+		}
+	})
+}
@@ -0,0 +1,193 @@
+package hastereport
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/jagoe/haste-client-go/haste"
+	"github.com/jagoe/haste-client-go/scan"
+	"github.com/jagoe/haste-client-go/server"
+)
+
+// DefaultTimeout is how long Recover waits for a report to be uploaded
+const DefaultTimeout = 30 * time.Second
+
+// Config determines where and how crash reports are uploaded
+type Config struct {
+	// Server is the haste-server instance reports are uploaded to, created by server.MakeHasteServer
+	Server server.HasteServer
+	// Logs are the last log lines that are included in reports
+	Logs *LogBuffer
+	// Scanner redacts possible secrets from reports; nil uses the default rules of the scan package
+	Scanner *scan.Scanner
+	// NoRedaction uploads reports without redacting possible secrets
+	NoRedaction bool
+	// Log reports the URL of an uploaded report or why it could not be uploaded, e.g. log.Printf; by default the
+	// message is printed to STDERR
+	Log func(format string, args ...interface{})
+	// Timeout is how long Recover waits for a report to be uploaded; 0 means DefaultTimeout
+	Timeout time.Duration
+}
+
+// Report describes a panic, so it can be shared in a bug report
+type Report struct {
+	Value interface{}
+	Time  time.Time
+	// Stack contains the stacks of all goroutines
+	Stack []byte
+	// Build is nil if the program has not been built with module support
+	Build *debug.BuildInfo
+	Logs  []string
+}
+
+// Recover uploads a report of a panic and panics again with the same value
+// It has to be deferred directly, e.g. defer hastereport.Recover(config), since it recovers the panic itself.
+func Recover(config Config) {
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	logf := config.Log
+	if logf == nil {
+		logf = func(format string, args ...interface{}) {
+			fmt.Fprintf(os.Stderr, format+"\n", args...)
+		}
+	}
+
+	url, err := Upload(config, Capture(value, config.Logs))
+	if err != nil {
+		logf("Error uploading crash report: %s", err.Error())
+	} else {
+		logf("Crash report: %s", url)
+	}
+
+	panic(value)
+}
+
+// Capture describes a panic with the stacks of all goroutines, the build info and the last log lines
+func Capture(value interface{}, logs *LogBuffer) Report {
+	report := Report{Value: value, Time: time.Now(), Stack: allStacks()}
+	report.Build, _ = debug.ReadBuildInfo()
+	if logs != nil {
+		report.Logs = logs.Lines()
+	}
+
+	return report
+}
+
+// Upload redacts possible secrets from a report and uploads it
+// Reports that exceed the maximum haste size of the server are uploaded in chunks.
+func Upload(config Config, report Report) (string, error) {
+	input := io.Reader(bytes.NewReader(report.Bytes()))
+	if !config.NoRedaction {
+		scanner := config.Scanner
+		if scanner == nil {
+			var err error
+			if scanner, err = scan.NewScanner(nil, nil); err != nil {
+				return "", err
+			}
+		}
+		input = scanner.Filter(input, scan.PolicyRedact)
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	writer := haste.NewWriter(config.Server)
+	writer.Chunked = true
+
+	// the upload cannot be canceled, but a crashing program should not wait for an unreachable server forever
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(writer, input)
+		if err != nil {
+			writer.Abort()
+			done <- err
+			return
+		}
+		done <- writer.Close()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return "", err
+		}
+		return writer.URL(), nil
+	case <-time.After(timeout):
+		return "", fmt.Errorf("Error creating haste: no response after %s", timeout)
+	}
+}
+
+// Bytes formats the report as a header that describes the panic and the program followed by logs and stacks
+func (report Report) Bytes() []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "panic: %s\n", describe(report.Value))
+	fmt.Fprintf(&out, "Time:     %s\n", report.Time.Format(time.RFC3339))
+	fmt.Fprintf(&out, "Go:       %s\n", runtime.Version())
+	fmt.Fprintf(&out, "Platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	if executable, err := os.Executable(); err == nil {
+		fmt.Fprintf(&out, "Program:  %s\n", executable)
+	}
+	if report.Build != nil {
+		fmt.Fprintf(&out, "Module:   %s %s\n", report.Build.Main.Path, report.Build.Main.Version)
+		if len(report.Build.Deps) > 0 {
+			fmt.Fprintln(&out, "Dependencies:")
+			for _, dep := range report.Build.Deps {
+				fmt.Fprintf(&out, "  %s %s\n", dep.Path, dep.Version)
+			}
+		}
+	}
+
+	if len(report.Logs) > 0 {
+		fmt.Fprintf(&out, "%s\n", strings.Repeat("-", 80))
+		for _, line := range report.Logs {
+			fmt.Fprintln(&out, strings.TrimSuffix(line, "\n"))
+		}
+	}
+
+	fmt.Fprintf(&out, "%s\n", strings.Repeat("-", 80))
+	out.Write(report.Stack)
+	if len(report.Stack) > 0 && !bytes.HasSuffix(report.Stack, []byte("\n")) {
+		out.WriteString("\n")
+	}
+
+	return out.Bytes()
+}
+
+// #region Private
+
+// describe formats the value of a panic like the runtime does
+func describe(value interface{}) string {
+	switch value := value.(type) {
+	case error:
+		return value.Error()
+	case fmt.Stringer:
+		return value.String()
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+// allStacks returns the stacks of all goroutines, growing the buffer until they fit
+func allStacks() []byte {
+	buffer := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buffer, true)
+		if n < len(buffer) || len(buffer) >= 64<<20 {
+			return buffer[:n]
+		}
+		buffer = make([]byte, 2*len(buffer))
+	}
+}
+
+// #endregion
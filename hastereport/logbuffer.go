@@ -0,0 +1,80 @@
+package hastereport
+
+import (
+	"sync"
+)
+
+// DefaultLogLines is the number of log lines a LogBuffer keeps by default
+const DefaultLogLines = 100
+
+// maxLineLength is the length after which an unterminated line is kept as a line of its own
+const maxLineLength = 64 << 10
+
+// LogBuffer keeps the last lines written to it, e.g. as an additional output of a logger:
+//
+//	logs := hastereport.NewLogBuffer(hastereport.DefaultLogLines)
+//	log.SetOutput(io.MultiWriter(os.Stderr, logs))
+//
+// It is safe for concurrent use.
+type LogBuffer struct {
+	mutex   sync.Mutex
+	lines   []string
+	next    int
+	full    bool
+	partial []byte
+}
+
+// NewLogBuffer creates a buffer that keeps the last count lines
+func NewLogBuffer(count int) *LogBuffer {
+	if count <= 0 {
+		count = DefaultLogLines
+	}
+
+	return &LogBuffer{lines: make([]string, count)}
+}
+
+// Write adds lines to the buffer, dropping the oldest ones; it never fails
+func (buffer *LogBuffer) Write(content []byte) (int, error) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	for _, b := range content {
+		buffer.partial = append(buffer.partial, b)
+		if b == '\n' || len(buffer.partial) >= maxLineLength {
+			buffer.add(string(buffer.partial))
+			buffer.partial = buffer.partial[:0]
+		}
+	}
+
+	return len(content), nil
+}
+
+// Lines returns the buffered lines from the oldest to the newest, including an unterminated last line
+func (buffer *LogBuffer) Lines() []string {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	var lines []string
+	if buffer.full {
+		lines = append(lines, buffer.lines[buffer.next:]...)
+	}
+	lines = append(lines, buffer.lines[:buffer.next]...)
+	if len(buffer.partial) > 0 {
+		lines = append(lines, string(buffer.partial))
+	}
+
+	return lines
+}
+
+// #region Private
+
+func (buffer *LogBuffer) add(line string) {
+	buffer.lines[buffer.next] = line
+	buffer.next++
+	if buffer.next == len(buffer.lines) {
+		buffer.next = 0
+		buffer.full = true
+	}
+}
+
+// #endregion
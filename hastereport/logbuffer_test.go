@@ -0,0 +1,34 @@
+package hastereport
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestLogBuffer(t *testing.T) {
+	tests := []struct {
+		name     string
+		count    int
+		writes   []string
+		expected []string
+	}{
+		{"should keep all lines below the limit", 3, []string{"a\n", "b\n"}, []string{"a\n", "b\n"}},
+		{"should drop the oldest lines", 2, []string{"a\nb\n", "c\n"}, []string{"b\n", "c\n"}},
+		{"should join lines written in parts", 2, []string{"a", "b\nc"}, []string{"ab\n", "c"}},
+		{"should wrap around several times", 2, []string{"1\n2\n3\n4\n5\n"}, []string{"4\n", "5\n"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buffer := NewLogBuffer(test.count)
+			for _, write := range test.writes {
+				fmt.Fprint(buffer, write)
+			}
+
+			if lines := buffer.Lines(); !reflect.DeepEqual(lines, test.expected) {
+				t.Errorf("Expected %q, got %q", test.expected, lines)
+			}
+		})
+	}
+}
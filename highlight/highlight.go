@@ -0,0 +1,402 @@
+package highlight
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Theme assigns ANSI colors to the tokens of a haste
+type Theme struct {
+	Keyword    string
+	String     string
+	Comment    string
+	Number     string
+	LineNumber string
+}
+
+// DefaultTheme is used if no theme is configured
+const DefaultTheme = "dark"
+
+// Themes are the available color themes by name
+var Themes = map[string]Theme{
+	"dark":  {Keyword: "\x1b[35m", String: "\x1b[32m", Comment: "\x1b[90m", Number: "\x1b[36m", LineNumber: "\x1b[90m"},
+	"light": {Keyword: "\x1b[34m", String: "\x1b[31m", Comment: "\x1b[2m", Number: "\x1b[35m", LineNumber: "\x1b[2m"},
+	"mono":  {Keyword: "\x1b[1m", String: "", Comment: "\x1b[2m", Number: "", LineNumber: "\x1b[2m"},
+}
+
+const reset = "\x1b[0m"
+
+// HTMLClasses are the CSS classes of the tokens in highlighted HTML
+var HTMLClasses = Theme{Keyword: "hl-keyword", String: "hl-string", Comment: "hl-comment", Number: "hl-number",
+	LineNumber: "hl-line"}
+
+// syntax describes the tokens of a language that are highlighted
+type syntax struct {
+	keywords     []string
+	lineComments []string
+	blockComment [2]string
+	// quotes are the string delimiters; strings delimited by multiLineQuotes may span several lines
+	quotes          string
+	multiLineQuotes string
+}
+
+var (
+	cStyle = syntax{lineComments: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: `"'`}
+	hashes = syntax{lineComments: []string{"#"}, quotes: `"'`}
+
+	syntaxes = map[string]syntax{
+		"go": withKeywords(syntax{lineComments: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"'`",
+			multiLineQuotes: "`"},
+			"break case chan const continue default defer else fallthrough for func go goto if import interface map "+
+				"package range return select struct switch type var nil true false"),
+		"js": withKeywords(syntax{lineComments: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"'`",
+			multiLineQuotes: "`"},
+			"async await break case catch class const continue default delete do else export extends finally for "+
+				"function if import in instanceof let new null return super switch this throw true false try typeof "+
+				"undefined var void while yield interface type enum implements"),
+		"c": withKeywords(cStyle,
+			"auto break case char const continue default do double else enum extern float for goto if int long "+
+				"register return short signed sizeof static struct switch typedef union unsigned void volatile while "+
+				"class namespace public private protected virtual template typename new delete this true false nullptr "+
+				"bool using"),
+		"java": withKeywords(cStyle,
+			"abstract boolean break byte case catch char class const continue default do double else enum extends "+
+				"final finally float for if implements import instanceof int interface long new null package private "+
+				"protected public return short static super switch this throw throws true false try void while var "+
+				"string namespace using fun val when object"),
+		"rs": withKeywords(cStyle,
+			"as async await break const continue crate else enum extern false fn for if impl in let loop match mod "+
+				"move mut pub ref return self Self static struct super trait true type unsafe use where while"),
+		"py": withKeywords(syntax{lineComments: []string{"#"}, quotes: `"'`},
+			"and as assert async await break class continue def del elif else except False finally for from global "+
+				"if import in is lambda None nonlocal not or pass raise return True try while with yield"),
+		"rb": withKeywords(hashes,
+			"alias and begin break case class def defined do else elsif end ensure false for if in module next nil "+
+				"not or redo rescue retry return self super then true undef unless until when while yield"),
+		"sh": withKeywords(hashes,
+			"if then else elif fi case esac for while until do done in function return local export readonly"),
+		"sql": withKeywords(syntax{lineComments: []string{"--"}, blockComment: [2]string{"/*", "*/"}, quotes: `'"`},
+			"select from where and or not insert into values update set delete create table drop alter index join "+
+				"left right inner outer on group by order having limit as null is in like distinct union primary key "+
+				"SELECT FROM WHERE AND OR NOT INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE DROP ALTER INDEX JOIN "+
+				"LEFT RIGHT INNER OUTER ON GROUP BY ORDER HAVING LIMIT AS NULL IS IN LIKE DISTINCT UNION PRIMARY KEY"),
+		"yaml": withKeywords(hashes, "true false null yes no on off"),
+		"json": withKeywords(syntax{quotes: `"`}, "true false null"),
+		"css":  withKeywords(syntax{blockComment: [2]string{"/*", "*/"}, quotes: `"'`}, "important"),
+		"html": {blockComment: [2]string{"<!--", "-->"}, quotes: `"'`},
+	}
+
+	aliases = map[string]string{
+		"golang": "go", "javascript": "js", "mjs": "js", "ts": "js", "typescript": "js", "jsx": "js", "tsx": "js",
+		"h": "c", "cpp": "c", "cc": "c", "hpp": "c", "cs": "java", "kt": "java", "scala": "java", "swift": "java",
+		"php": "java", "rust": "rs", "python": "py", "ruby": "rb", "bash": "sh", "zsh": "sh", "ps1": "sh",
+		"dockerfile": "sh", "makefile": "sh", "mk": "sh", "toml": "yaml", "ini": "yaml", "yml": "yaml",
+		"xml": "html", "htm": "html", "scss": "css", "less": "css", "diff": "diff", "patch": "diff",
+	}
+)
+
+// Names returns the names of the available themes
+func Names() []string {
+	var names []string
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Lookup returns the theme with the provided name
+func Lookup(name string) (Theme, error) {
+	theme, ok := Themes[strings.ToLower(name)]
+	if !ok {
+		return Theme{}, fmt.Errorf("Unknown theme '%s': expected %s", name, strings.Join(Names(), "|"))
+	}
+
+	return theme, nil
+}
+
+// Supports reports whether text with the provided extension is highlighted
+func Supports(extension string) bool {
+	_, ok := lookupSyntax(extension)
+	return ok || normalize(extension) == "diff"
+}
+
+// Highlight colors keywords, strings, comments and numbers of text in a language determined by its extension
+// Text in unknown languages is returned unchanged. Control characters should be escaped before.
+func Highlight(text string, extension string, theme Theme) string {
+	return highlight(text, extension, ansi{theme})
+}
+
+// HTML escapes text and wraps its tokens in spans with the HTMLClasses
+// Every line of the result is valid HTML on its own, so the result can be split into lines.
+func HTML(text string, extension string) string {
+	return highlight(text, extension, htmlFormat{})
+}
+
+// NumberLines prefixes every line with its number, starting at first
+func NumberLines(text string, first int, theme Theme) string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	width := len(fmt.Sprint(first + len(lines) - 1))
+	var out strings.Builder
+	for i, line := range lines {
+		out.WriteString(paint(fmt.Sprintf("%*d │ ", width, first+i), theme.LineNumber))
+		out.WriteString(line)
+	}
+
+	return out.String()
+}
+
+// #region Private
+
+// format writes the tokens of a haste; plain text is written with an empty class
+type format interface {
+	write(out *strings.Builder, text string, class func(Theme) string)
+}
+
+type ansi struct {
+	theme Theme
+}
+
+func (format ansi) write(out *strings.Builder, text string, class func(Theme) string) {
+	out.WriteString(paint(text, class(format.theme)))
+}
+
+type htmlFormat struct{}
+
+func (htmlFormat) write(out *strings.Builder, text string, class func(Theme) string) {
+	name := class(HTMLClasses)
+	if name == "" || text == "" {
+		out.WriteString(html.EscapeString(text))
+		return
+	}
+
+	fmt.Fprintf(out, `<span class="%s">%s</span>`, name, html.EscapeString(text))
+}
+
+var (
+	plain   = func(Theme) string { return "" }
+	keyword = func(theme Theme) string { return theme.Keyword }
+	str     = func(theme Theme) string { return theme.String }
+	comment = func(theme Theme) string { return theme.Comment }
+	number  = func(theme Theme) string { return theme.Number }
+)
+
+func highlight(text string, extension string, format format) string {
+	if normalize(extension) == "diff" {
+		return highlightDiff(text, format)
+	}
+
+	language, ok := lookupSyntax(extension)
+	if !ok {
+		var out strings.Builder
+		format.write(&out, text, plain)
+		return out.String()
+	}
+
+	keywords := map[string]bool{}
+	for _, keyword := range language.keywords {
+		keywords[keyword] = true
+	}
+
+	var out strings.Builder
+	var state scanState
+	for _, line := range strings.SplitAfter(text, "\n") {
+		state = highlightLine(&out, line, language, keywords, format, state)
+	}
+
+	return out.String()
+}
+
+type scanState struct {
+	inComment bool
+	quote     rune
+}
+
+func withKeywords(language syntax, keywords string) syntax {
+	language.keywords = strings.Fields(keywords)
+	return language
+}
+
+func normalize(extension string) string {
+	extension = strings.ToLower(strings.TrimPrefix(extension, "."))
+	if alias, ok := aliases[extension]; ok {
+		return alias
+	}
+
+	return extension
+}
+
+func lookupSyntax(extension string) (syntax, bool) {
+	language, ok := syntaxes[normalize(extension)]
+	return language, ok
+}
+
+func highlightLine(out *strings.Builder, line string, language syntax, keywords map[string]bool, format format,
+	state scanState) scanState {
+	for i := 0; i < len(line); {
+		rest := line[i:]
+
+		switch {
+		case state.inComment:
+			end := strings.Index(rest, language.blockComment[1])
+			if end < 0 {
+				writeToken(out, format, rest, comment)
+				return state
+			}
+			end += len(language.blockComment[1])
+			writeToken(out, format, rest[:end], comment)
+			state.inComment = false
+			i += end
+			continue
+		case state.quote != 0:
+			end := closingQuote(rest, state.quote)
+			if end < 0 {
+				writeToken(out, format, rest, str)
+				if !strings.ContainsRune(language.multiLineQuotes, state.quote) {
+					state.quote = 0
+				}
+				return state
+			}
+			writeToken(out, format, rest[:end], str)
+			state.quote = 0
+			i += end
+			continue
+		}
+
+		if hasAnyPrefix(rest, language.lineComments) {
+			writeToken(out, format, rest, comment)
+			return state
+		}
+
+		if language.blockComment[0] != "" && strings.HasPrefix(rest, language.blockComment[0]) {
+			start := len(language.blockComment[0])
+			end := strings.Index(rest[start:], language.blockComment[1])
+			if end < 0 {
+				writeToken(out, format, rest, comment)
+				state.inComment = true
+				return state
+			}
+			end += start + len(language.blockComment[1])
+			writeToken(out, format, rest[:end], comment)
+			i += end
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		switch {
+		case strings.ContainsRune(language.quotes, r):
+			end := closingQuote(rest[size:], r)
+			if end < 0 {
+				writeToken(out, format, rest, str)
+				if strings.ContainsRune(language.multiLineQuotes, r) {
+					state.quote = r
+				}
+				return state
+			}
+			writeToken(out, format, rest[:size+end], str)
+			i += size + end
+		case unicode.IsDigit(r) && (i == 0 || !isWordByte(line[i-1])):
+			end := tokenEnd(rest, func(r rune) bool { return unicode.IsDigit(r) || strings.ContainsRune("._xXabcdefABCDEF", r) })
+			writeToken(out, format, rest[:end], number)
+			i += end
+		case unicode.IsLetter(r) || r == '_':
+			end := tokenEnd(rest, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' })
+			word := rest[:end]
+			if keywords[word] {
+				writeToken(out, format, word, keyword)
+			} else {
+				format.write(out, word, plain)
+			}
+			i += end
+		default:
+			format.write(out, rest[:size], plain)
+			i += size
+		}
+	}
+
+	return state
+}
+
+// closingQuote returns the end of a string after its closing quote or -1 if the string does not end in the text
+func closingQuote(text string, quote rune) int {
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && quote != '`':
+			i++
+		case rune(text[i]) == quote:
+			return i + 1
+		}
+	}
+
+	return -1
+}
+
+func highlightDiff(text string, format format) string {
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			writeToken(&out, format, line, keyword)
+		case strings.HasPrefix(line, "@@"):
+			writeToken(&out, format, line, number)
+		case strings.HasPrefix(line, "+"):
+			writeToken(&out, format, line, str)
+		case strings.HasPrefix(line, "-"):
+			writeToken(&out, format, line, comment)
+		default:
+			format.write(&out, line, plain)
+		}
+	}
+
+	return out.String()
+}
+
+func tokenEnd(text string, inToken func(rune) bool) int {
+	for i, r := range text {
+		if !inToken(r) {
+			return i
+		}
+	}
+
+	return len(text)
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+func hasAnyPrefix(text string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeToken formats a token; line feeds stay outside of the colors, so they do not bleed into the next line
+func writeToken(out *strings.Builder, format format, token string, class func(Theme) string) {
+	content := strings.TrimRight(token, "\r\n")
+	format.write(out, content, class)
+	format.write(out, token[len(content):], plain)
+}
+
+func paint(text string, color string) string {
+	if color == "" || text == "" {
+		return text
+	}
+
+	return color + text + reset
+}
+
+// #endregion
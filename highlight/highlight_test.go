@@ -0,0 +1,67 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+)
+
+var testTheme = Theme{Keyword: "<k>", String: "<s>", Comment: "<c>", Number: "<n>", LineNumber: "<l>"}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name      string
+		extension string
+		text      string
+		expected  string
+	}{
+		{"should color keywords, strings and numbers", "go", "return \"a\\\"b\", 42\n",
+			"<k>return\x1b[0m <s>\"a\\\"b\"\x1b[0m, <n>42\x1b[0m\n"},
+		{"should color line comments", "py", "x = 1 # note\n", "x = <n>1\x1b[0m <c># note\x1b[0m\n"},
+		{"should color block comments across lines", ".js", "/* a\nb */ let\n",
+			"<c>/* a\x1b[0m\n<c>b */\x1b[0m <k>let\x1b[0m\n"},
+		{"should not color keywords within words", "go", "returned\n", "returned\n"},
+		{"should resolve aliases", "yml", "on: true\n", "<k>on\x1b[0m: <k>true\x1b[0m\n"},
+		{"should color diffs", "diff", "@@ -1 +1 @@\n-a\n+b\n",
+			"<n>@@ -1 +1 @@\x1b[0m\n<c>-a\x1b[0m\n<s>+b\x1b[0m\n"},
+		{"should return unknown languages unchanged", "unknown", "return 1\n", "return 1\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if highlighted := Highlight(test.text, test.extension, testTheme); highlighted != test.expected {
+				t.Errorf("Expected\n%q\ngot\n%q", test.expected, highlighted)
+			}
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	highlighted := HTML("/* <a>\nb */ x := \"&\"\n<b>\n", "go")
+	expected := `<span class="hl-comment">/* &lt;a&gt;</span>` + "\n" +
+		`<span class="hl-comment">b */</span> x := <span class="hl-string">&#34;&amp;&#34;</span>` + "\n&lt;b&gt;\n"
+	if highlighted != expected {
+		t.Errorf("Expected\n%q\ngot\n%q", expected, highlighted)
+	}
+
+	if escaped := HTML("<script>\n", "unknown"); escaped != "&lt;script&gt;\n" {
+		t.Errorf("Expected unknown languages to be escaped, got %q", escaped)
+	}
+}
+
+func TestNumberLines(t *testing.T) {
+	numbered := NumberLines("a\nb\n", 9, testTheme)
+	expected := "<l> 9 │ \x1b[0ma\n<l>10 │ \x1b[0mb\n"
+	if numbered != expected {
+		t.Errorf("Expected\n%q\ngot\n%q", expected, numbered)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if _, err := Lookup("Dark"); err != nil {
+		t.Errorf("Should not have returned error: %s", err.Error())
+	}
+
+	if _, err := Lookup("neon"); err == nil || !strings.Contains(err.Error(), "dark|light|mono") {
+		t.Errorf("Expected an error listing the themes, got %v", err)
+	}
+}
@@ -0,0 +1,325 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jagoe/haste-client-go/util"
+)
+
+// #region Setup
+
+const (
+	// ActionCreate marks hastes that have been created
+	ActionCreate = "create"
+	// ActionGet marks hastes that have been retrieved
+	ActionGet = "get"
+
+	lockTimeout = 5 * time.Second
+)
+
+// Entry is a haste that has been created or retrieved
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	URL    string    `json:"url"`
+	Key    string    `json:"key"`
+	Server string    `json:"server"`
+	Source string    `json:"source,omitempty"`
+	Size   int64     `json:"size"`
+	SHA256 string    `json:"sha256"`
+	// Parent is the URL of the haste a revision has been edited from
+	Parent string `json:"parent,omitempty"`
+}
+
+// Filter selects history entries; empty fields match every entry
+type Filter struct {
+	Since  time.Time
+	Until  time.Time
+	Server string
+	Source string
+	Key    string
+	Action string
+}
+
+// Store persists the history as JSON lines in a single file
+type Store struct {
+	Path string
+}
+
+// DefaultPath returns the location of the history file in the XDG data directory
+func DefaultPath() (string, error) {
+	dir, err := util.DataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+// MakeEntry creates a history entry for a haste and calculates size and checksum of its content
+func MakeEntry(action string, serverURL string, key string, source string, content []byte) Entry {
+	sum := sha256.Sum256(content)
+
+	return Entry{
+		Time:   time.Now().UTC(),
+		Action: action,
+		URL:    fmt.Sprintf("%s/%s", serverURL, key),
+		Key:    key,
+		Server: serverURL,
+		Source: source,
+		Size:   int64(len(content)),
+		SHA256: hex.EncodeToString(sum[:]),
+	}
+}
+
+// #endregion
+
+// Add appends an entry to the history
+func (store Store) Add(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("Error writing history: %s", err.Error())
+	}
+
+	if err := os.MkdirAll(filepath.Dir(store.Path), 0700); err != nil {
+		return fmt.Errorf("Error writing history: %s", err.Error())
+	}
+
+	unlock, err := util.LockFile(store.Path, lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	file, err := os.OpenFile(store.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("Error writing history: %s", err.Error())
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("Error writing history: %s", err.Error())
+	}
+
+	return nil
+}
+
+// List returns all entries that match the filter, oldest first
+func (store Store) List(filter Filter) ([]Entry, error) {
+	entries, err := store.read()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Entry
+	for _, entry := range entries {
+		if filter.Matches(entry) {
+			matches = append(matches, entry)
+		}
+	}
+
+	return matches, nil
+}
+
+// Last returns the most recent entry that matches the filter
+func (store Store) Last(filter Filter) (*Entry, error) {
+	entries, err := store.List(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("No matching hastes in history")
+	}
+
+	return &entries[len(entries)-1], nil
+}
+
+// Delete removes all entries that match the filter and returns how many were removed
+func (store Store) Delete(filter Filter) (int, error) {
+	unlock, err := util.LockFile(store.Path, lockTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	entries, err := store.read()
+	if err != nil {
+		return 0, err
+	}
+
+	var kept bytes.Buffer
+	deleted := 0
+	for _, entry := range entries {
+		if filter.Matches(entry) {
+			deleted++
+			continue
+		}
+
+		line, _ := json.Marshal(entry)
+		kept.Write(append(line, '\n'))
+	}
+
+	if deleted == 0 {
+		return 0, nil
+	}
+
+	temp := store.Path + ".tmp"
+	if err := ioutil.WriteFile(temp, kept.Bytes(), 0600); err != nil {
+		return 0, fmt.Errorf("Error writing history: %s", err.Error())
+	}
+
+	if err := os.Rename(temp, store.Path); err != nil {
+		os.Remove(temp)
+		return 0, fmt.Errorf("Error writing history: %s", err.Error())
+	}
+
+	return deleted, nil
+}
+
+// Matches reports whether an entry is selected by the filter
+// Server and source match on substrings.
+func (filter Filter) Matches(entry Entry) bool {
+	return (filter.Since.IsZero() || !entry.Time.Before(filter.Since)) &&
+		(filter.Until.IsZero() || entry.Time.Before(filter.Until)) &&
+		(filter.Server == "" || strings.Contains(entry.Server, filter.Server)) &&
+		(filter.Source == "" || strings.Contains(entry.Source, filter.Source)) &&
+		(filter.Key == "" || entry.Key == filter.Key || entry.URL == filter.Key) &&
+		(filter.Action == "" || entry.Action == filter.Action)
+}
+
+// Revisions returns the lineage of a haste, oldest first: the hastes it has been edited from and all revisions that
+// have been edited from them
+// Hastes that are only known as the parent of a revision are included without time, size and checksum.
+func (store Store) Revisions(key string) ([]Entry, error) {
+	entries, err := store.read()
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]Entry{}
+	parents := map[string]string{}
+	children := map[string][]string{}
+	url := ""
+	for _, entry := range entries {
+		if _, ok := known[entry.URL]; !ok || (entry.Action == ActionCreate && known[entry.URL].Action != ActionCreate) {
+			known[entry.URL] = entry
+		}
+		if entry.Parent != "" && parents[entry.URL] == "" {
+			parents[entry.URL] = entry.Parent
+			children[entry.Parent] = append(children[entry.Parent], entry.URL)
+		}
+		if url == "" && (entry.Key == key || entry.URL == key) {
+			url = entry.URL
+		}
+	}
+
+	if url == "" && children[key] != nil {
+		// the haste is only known as the parent of a revision
+		url = key
+	}
+	if url == "" {
+		return nil, fmt.Errorf("No revisions of %s in history", key)
+	}
+
+	// walk up to the original haste and collect all of its revisions from there
+	root := url
+	seen := map[string]bool{root: true}
+	for parents[root] != "" && !seen[parents[root]] {
+		root = parents[root]
+		seen[root] = true
+	}
+
+	var lineage []Entry
+	visited := map[string]bool{}
+	queue := []string{root}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+
+		entry, ok := known[current]
+		if !ok {
+			entry = Entry{URL: current}
+		}
+		entry.Parent = parents[current]
+		lineage = append(lineage, entry)
+		queue = append(queue, children[current]...)
+	}
+
+	sort.SliceStable(lineage, func(i, j int) bool { return lineage[i].Time.Before(lineage[j].Time) })
+	return lineage, nil
+}
+
+// ParseTime parses absolute dates (2006-01-02, RFC 3339) and relative durations (90m, 24h, 7d) into a point in time
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+
+	if strings.HasSuffix(value, "d") {
+		var days int
+		if _, err := fmt.Sscanf(value, "%dd", &days); err == nil {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+
+	return time.Time{}, fmt.Errorf("Invalid date '%s': expected YYYY-MM-DD, RFC 3339 or a duration like 24h or 7d", value)
+}
+
+// #region Private
+
+func (store Store) read() ([]Entry, error) {
+	file, err := os.Open(store.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading history: %s", err.Error())
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// skip lines that have been corrupted, e.g. by a full disk
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading history: %s", err.Error())
+	}
+
+	return entries, nil
+}
+
+// #endregion
@@ -0,0 +1,160 @@
+package history
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func prepareStore(t *testing.T) Store {
+	dir, err := ioutil.TempDir("", "haste-test")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err.Error())
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return Store{Path: filepath.Join(dir, "data", "history.jsonl")}
+}
+
+func TestStore(t *testing.T) {
+	t.Run("should add and list entries", func(t *testing.T) {
+		store := prepareStore(t)
+		first := MakeEntry(ActionCreate, "https://hastebin", "abc", "main.go", []byte("content"))
+		second := MakeEntry(ActionGet, "https://other", "def", "", []byte("other"))
+
+		for _, entry := range []Entry{first, second} {
+			if err := store.Add(entry); err != nil {
+				t.Fatalf("Should not have returned error: %s", err.Error())
+			}
+		}
+
+		entries, err := store.List(Filter{})
+		if err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		if len(entries) != 2 || entries[0].URL != "https://hastebin/abc" || entries[1].Key != "def" {
+			t.Fatalf("Unexpected entries: %+v", entries)
+		}
+
+		filtered, _ := store.List(Filter{Server: "other"})
+		if len(filtered) != 1 || filtered[0].Key != "def" {
+			t.Errorf("Expected server filter to return only 'def', got %+v", filtered)
+		}
+
+		last, err := store.Last(Filter{Action: ActionCreate})
+		if err != nil || last.Key != "abc" {
+			t.Errorf("Expected last created haste to be 'abc', got %+v (%v)", last, err)
+		}
+	})
+
+	t.Run("should delete matching entries", func(t *testing.T) {
+		store := prepareStore(t)
+		store.Add(MakeEntry(ActionCreate, "https://hastebin", "abc", "main.go", nil))
+		store.Add(MakeEntry(ActionCreate, "https://hastebin", "def", "go.mod", nil))
+
+		deleted, err := store.Delete(Filter{Source: "main"})
+		if err != nil || deleted != 1 {
+			t.Fatalf("Expected 1 deleted entry, got %d (%v)", deleted, err)
+		}
+
+		entries, _ := store.List(Filter{})
+		if len(entries) != 1 || entries[0].Key != "def" {
+			t.Errorf("Expected only 'def' to remain, got %+v", entries)
+		}
+	})
+
+	t.Run("should handle parallel writers", func(t *testing.T) {
+		store := prepareStore(t)
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if err := store.Add(MakeEntry(ActionCreate, "https://hastebin", fmt.Sprint(i), "", nil)); err != nil {
+					t.Errorf("Should not have returned error: %s", err.Error())
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		entries, _ := store.List(Filter{})
+		if len(entries) != 20 {
+			t.Errorf("Expected 20 entries, got %d", len(entries))
+		}
+	})
+}
+
+func TestRevisions(t *testing.T) {
+	store := prepareStore(t)
+
+	revision := func(key string, parent string, offset time.Duration) Entry {
+		entry := MakeEntry(ActionCreate, "https://hastebin", key, "", []byte(key))
+		entry.Time = entry.Time.Add(offset)
+		entry.Parent = parent
+		return entry
+	}
+
+	for _, entry := range []Entry{
+		revision("b", "https://hastebin/a", time.Minute),
+		revision("c", "https://hastebin/b", 2*time.Minute),
+		revision("d", "https://hastebin/b", 3*time.Minute),
+		revision("x", "", 4*time.Minute),
+	} {
+		store.Add(entry)
+	}
+
+	for _, key := range []string{"c", "https://hastebin/a"} {
+		lineage, err := store.Revisions(key)
+		if err != nil {
+			t.Fatalf("Should not have returned error: %s", err.Error())
+		}
+
+		var urls []string
+		for _, entry := range lineage {
+			urls = append(urls, entry.URL)
+		}
+
+		expected := "[https://hastebin/a https://hastebin/b https://hastebin/c https://hastebin/d]"
+		if fmt.Sprint(urls) != expected {
+			t.Errorf("%s: Expected lineage %s, got %v", key, expected, urls)
+		}
+	}
+
+	if _, err := store.Revisions("unknown"); err == nil {
+		t.Error("Expected an unknown haste to return an error")
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value    string
+		expected time.Time
+	}{
+		{"", time.Time{}},
+		{"2020-06-01", time.Date(2020, 6, 1, 0, 0, 0, 0, time.Local)},
+		{"2020-06-01T10:00:00Z", time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)},
+		{"2h", now.Add(-2 * time.Hour)},
+		{"7d", now.AddDate(0, 0, -7)},
+	}
+
+	for _, test := range tests {
+		parsed, err := ParseTime(test.value, now)
+		if err != nil {
+			t.Fatalf("Should not have returned error for '%s': %s", test.value, err.Error())
+		}
+
+		if !parsed.Equal(test.expected) {
+			t.Errorf("Expected '%s' to be parsed as %s, got %s", test.value, test.expected, parsed)
+		}
+	}
+
+	if _, err := ParseTime("yesterday", now); err == nil {
+		t.Errorf("Expected an error for an invalid date")
+	}
+}
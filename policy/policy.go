@@ -0,0 +1,192 @@
+package policy
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/jagoe/haste-client-go/util"
+	"github.com/spf13/viper"
+)
+
+// #region Setup
+
+// Policy is an organization policy that is loaded from a system-level config before the user config
+type Policy struct {
+	// Path is the location of the policy file
+	Path string
+	// Settings are applied as defaults, so the user config, ENV variables and flags can override them unless locked
+	Settings map[string]interface{}
+	// Locked are the keys of settings that must not be overridden
+	Locked []string
+	// AllowedServers are the only servers hastes may be created on or read from; if empty, only a locked server is
+	// allowed or all servers if the server is not locked
+	AllowedServers []string
+	// RequireSecretScanning refuses to disable the secret scan before uploads
+	RequireSecretScanning bool
+	// RequireEncryption refuses uploads that are not encrypted
+	RequireEncryption bool
+}
+
+// DefaultPath returns the location of the system-level policy file
+func DefaultPath() string {
+	if dir := os.Getenv("ProgramData"); dir != "" && runtime.GOOS == "windows" {
+		return filepath.Join(dir, util.AppName, "policy.yaml")
+	}
+
+	return filepath.Join("/etc", util.AppName, "policy.yaml")
+}
+
+// Load reads a policy file
+// If there is no policy file, no policy applies and nil is returned.
+func Load(path string) (*Policy, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	config := viper.New()
+	config.SetConfigFile(path)
+	if err := config.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("Error reading policy: %s", err.Error())
+	}
+
+	policy := &Policy{
+		Path:                  path,
+		Settings:              map[string]interface{}{},
+		AllowedServers:        config.GetStringSlice("allowedServers"),
+		RequireSecretScanning: config.GetBool("requireSecretScanning"),
+		RequireEncryption:     config.GetBool("requireEncryption"),
+	}
+
+	if settings := config.Sub("settings"); settings != nil {
+		for _, key := range settings.AllKeys() {
+			policy.Settings[key] = settings.Get(key)
+		}
+	}
+
+	for _, key := range config.GetStringSlice("locked") {
+		// viper keys are case insensitive
+		key = strings.ToLower(key)
+		if _, ok := policy.Settings[key]; !ok {
+			return nil, fmt.Errorf("Error reading policy: locked key '%s' has no value in settings", key)
+		}
+
+		policy.Locked = append(policy.Locked, key)
+	}
+
+	return policy, nil
+}
+
+// #endregion
+
+// Apply sets the settings of the policy as defaults of the config
+func (policy *Policy) Apply(config *viper.Viper) {
+	if policy == nil {
+		return
+	}
+
+	for key, value := range policy.Settings {
+		config.SetDefault(key, value)
+	}
+}
+
+// Check refuses configs that override locked settings or disable required features
+// It has to be called after the user config, ENV variables and flags have been read.
+func (policy *Policy) Check(config *viper.Viper) error {
+	if policy == nil {
+		return nil
+	}
+
+	for _, key := range policy.Locked {
+		if fmt.Sprint(config.Get(key)) != fmt.Sprint(policy.Settings[key]) {
+			return fmt.Errorf("'%s' is locked to '%v' by the organization policy (%s)", key, policy.Settings[key], policy.Path)
+		}
+	}
+
+	if policy.RequireSecretScanning && config.GetString("secrets.policy") == "off" {
+		return fmt.Errorf("Secret scanning is required by the organization policy (%s)", policy.Path)
+	}
+
+	return nil
+}
+
+// CheckServer refuses servers that are not in the list of allowed servers
+// A locked server is the only allowed one if there is no such list.
+func (policy *Policy) CheckServer(serverURL string) error {
+	if policy == nil {
+		return nil
+	}
+
+	allowedServers := policy.AllowedServers
+	if len(allowedServers) == 0 && policy.isLocked("server") {
+		allowedServers = []string{fmt.Sprint(policy.Settings["server"])}
+	}
+	if len(allowedServers) == 0 {
+		return nil
+	}
+
+	for _, allowed := range allowedServers {
+		if matchesServer(allowed, serverURL) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Server %s is not allowed by the organization policy (%s)", serverURL, policy.Path)
+}
+
+// CheckCreate refuses uploads that the policy does not allow
+func (policy *Policy) CheckCreate(serverURL string) error {
+	if policy == nil {
+		return nil
+	}
+
+	if policy.RequireEncryption {
+		// this client cannot encrypt hastes (yet), so nothing may be uploaded
+		return fmt.Errorf("Encryption is required by the organization policy (%s), but this client cannot encrypt hastes",
+			policy.Path)
+	}
+
+	return policy.CheckServer(serverURL)
+}
+
+// #region Private
+
+func (policy *Policy) isLocked(key string) bool {
+	for _, locked := range policy.Locked {
+		if locked == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesServer compares the scheme, host and path of two server URLs
+// Allowed servers without a scheme match any scheme.
+func matchesServer(allowed string, serverURL string) bool {
+	if !strings.Contains(allowed, "://") {
+		allowed = "//" + allowed
+	}
+
+	allowedURL, err := url.Parse(allowed)
+	if err != nil {
+		return false
+	}
+
+	actual, err := url.Parse(serverURL)
+	if err != nil {
+		return false
+	}
+
+	if allowedURL.Scheme != "" && !strings.EqualFold(allowedURL.Scheme, actual.Scheme) {
+		return false
+	}
+
+	return strings.EqualFold(allowedURL.Host, actual.Host) &&
+		strings.TrimSuffix(allowedURL.Path, "/") == strings.TrimSuffix(actual.Path, "/")
+}
+
+// #endregion
@@ -0,0 +1,144 @@
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// #region Setup
+
+func writePolicy(t *testing.T, content string) string {
+	dir, _ := ioutil.TempDir("", "haste-policy")
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "policy.yaml")
+	ioutil.WriteFile(path, []byte(content), 0644)
+
+	return path
+}
+
+const testPolicy = `
+settings:
+  server: https://haste.example.com
+  secrets:
+    policy: block
+locked:
+  - server
+  - secrets.policy
+allowedServers:
+  - https://haste.example.com
+  - paste.example.com
+requireSecretScanning: true
+`
+
+// #endregion
+
+func TestLoad(t *testing.T) {
+	t.Run("should ignore a missing policy file", func(t *testing.T) {
+		policy, err := Load(filepath.Join(os.TempDir(), "does-not-exist.yaml"))
+		if policy != nil || err != nil {
+			t.Errorf("Expected no policy and no error, got %+v, %v", policy, err)
+		}
+	})
+
+	t.Run("should read settings and locked keys", func(t *testing.T) {
+		policy, err := Load(writePolicy(t, testPolicy))
+		if err != nil {
+			t.Fatalf("Expected Load not to return an error, got %s", err.Error())
+		}
+
+		if policy.Settings["secrets.policy"] != "block" || len(policy.Locked) != 2 || len(policy.AllowedServers) != 2 {
+			t.Errorf("Expected the policy to be read completely, got %+v", policy)
+		}
+	})
+
+	t.Run("should reject locked keys without a value", func(t *testing.T) {
+		if _, err := Load(writePolicy(t, "locked:\n  - server\n")); err == nil {
+			t.Error("Expected Load to return an error")
+		}
+	})
+}
+
+func TestCheck(t *testing.T) {
+	policy, _ := Load(writePolicy(t, testPolicy))
+
+	t.Run("should accept the policy settings", func(t *testing.T) {
+		config := viper.New()
+		policy.Apply(config)
+
+		if err := policy.Check(config); err != nil {
+			t.Errorf("Expected Check not to return an error, got %s", err.Error())
+		}
+	})
+
+	t.Run("should refuse overriding a locked key", func(t *testing.T) {
+		config := viper.New()
+		policy.Apply(config)
+		config.Set("server", "https://hastebin.com")
+
+		if err := policy.Check(config); err == nil {
+			t.Error("Expected Check to return an error")
+		}
+	})
+
+	t.Run("should refuse disabling secret scanning", func(t *testing.T) {
+		policy := &Policy{RequireSecretScanning: true}
+		config := viper.New()
+		config.Set("secrets.policy", "off")
+
+		if err := policy.Check(config); err == nil {
+			t.Error("Expected Check to return an error")
+		}
+	})
+
+	t.Run("should allow everything without a policy", func(t *testing.T) {
+		var policy *Policy
+		if policy.Check(viper.New()) != nil || policy.CheckCreate("https://hastebin.com") != nil {
+			t.Error("Expected a missing policy to allow everything")
+		}
+	})
+}
+
+func TestCheckServer(t *testing.T) {
+	policy, _ := Load(writePolicy(t, testPolicy))
+
+	for serverURL, allowed := range map[string]bool{
+		"https://haste.example.com":  true,
+		"https://haste.example.com/": true,
+		"http://haste.example.com":   false,
+		"http://paste.example.com":   true,
+		"https://hastebin.com":       false,
+		"https://haste.example.com.": false,
+	} {
+		if err := policy.CheckServer(serverURL); (err == nil) != allowed {
+			t.Errorf("Expected %s to be allowed: %t, got %v", serverURL, allowed, err)
+		}
+	}
+}
+
+func TestCheckServerWithLockedServer(t *testing.T) {
+	policy, _ := Load(writePolicy(t, "settings:\n  server: https://haste.example.com\nlocked:\n  - server\n"))
+
+	if err := policy.CheckServer("https://haste.example.com"); err != nil {
+		t.Errorf("Expected the locked server to be allowed, got %s", err.Error())
+	}
+	if err := policy.CheckServer("https://hastebin.com"); err == nil {
+		t.Error("Expected other servers than the locked one to be refused")
+	}
+
+	unlocked := &Policy{Settings: map[string]interface{}{"server": "https://haste.example.com"}}
+	if err := unlocked.CheckServer("https://hastebin.com"); err != nil {
+		t.Errorf("Expected all servers to be allowed without a locked server, got %s", err.Error())
+	}
+}
+
+func TestCheckCreate(t *testing.T) {
+	policy := &Policy{RequireEncryption: true}
+	if err := policy.CheckCreate("https://hastebin.com"); err == nil {
+		t.Error("Expected CheckCreate to refuse unencrypted uploads")
+	}
+}
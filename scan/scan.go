@@ -0,0 +1,365 @@
+package scan
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// #region Setup
+
+// Policy determines what happens if possible secrets are found
+type Policy string
+
+const (
+	// PolicyOff disables secret scanning
+	PolicyOff Policy = "off"
+	// PolicyBlock aborts the upload
+	PolicyBlock Policy = "block"
+	// PolicyWarn asks for confirmation before anything is uploaded
+	PolicyWarn Policy = "warn"
+	// PolicyRedact replaces possible secrets before they are uploaded
+	PolicyRedact Policy = "redact"
+)
+
+const (
+	entropyRuleName    = "high-entropy string"
+	entropyThreshold   = 4.0
+	privateKeyRuleName = "private key"
+)
+
+// Rule is a named pattern that matches a kind of secret
+type Rule struct {
+	Name    string `mapstructure:"name"`
+	Pattern string `mapstructure:"pattern"`
+
+	expression *regexp.Regexp
+}
+
+// Finding is a possible secret that has been found in the scanned content
+type Finding struct {
+	Rule string
+	Line int
+	// Match is masked, so findings can be printed safely
+	Match string
+}
+
+func (finding Finding) String() string {
+	return fmt.Sprintf("line %d: %s (%s)", finding.Line, finding.Rule, finding.Match)
+}
+
+// FoundError is returned if possible secrets are found and the policy does not allow to upload them
+type FoundError struct {
+	Findings []Finding
+}
+
+func (err *FoundError) Error() string {
+	lines := make([]string, len(err.Findings))
+	for i, finding := range err.Findings {
+		lines[i] = "  " + finding.String()
+	}
+
+	return fmt.Sprintf("Possible secrets found, upload aborted:\n%s", strings.Join(lines, "\n"))
+}
+
+// DefaultRules match common secrets
+var DefaultRules = []Rule{
+	{Name: "AWS access key", Pattern: `\b(AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "AWS secret key", Pattern: `(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}`},
+	{Name: "Google API key", Pattern: `\bAIza[0-9A-Za-z_\-]{35}\b`},
+	{Name: "GitHub token", Pattern: `\bgh[pousr]_[A-Za-z0-9]{36,}\b`},
+	{Name: "Slack token", Pattern: `\bxox[abprs]-[A-Za-z0-9-]{10,}`},
+	{Name: "Azure storage key", Pattern: `AccountKey=[A-Za-z0-9+/=]{80,}`},
+	{Name: privateKeyRuleName, Pattern: `-----BEGIN ((RSA|EC|DSA|OPENSSH|PGP|ENCRYPTED) )?PRIVATE KEY( BLOCK)?-----`},
+	{Name: "JWT", Pattern: `\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`},
+	{Name: ".env secret", Pattern: `(?i)^\s*(export\s+)?[A-Z0-9_]*(SECRET|PASSWORD|PASSWD|TOKEN|API_?KEY|PRIVATE_?KEY|CREDENTIALS?)[A-Z0-9_]*\s*=\s*["']?[^\s"'$]{4,}`},
+}
+
+// privateKeyEnd ends a PEM private key whose header has been matched by the private key rule
+var privateKeyEnd = regexp.MustCompile(`-----END ((RSA|EC|DSA|OPENSSH|PGP|ENCRYPTED) )?PRIVATE KEY( BLOCK)?-----`)
+
+// entropyCandidates are values assigned to secret-looking keys, e.g. token: ... or "apiKey": "...", that are checked
+// for high entropy; the first group is a checksum prefix like the h1: of go.sum, the second group is the value
+var entropyCandidates = regexp.MustCompile(`(?i)(?:secret|token|passw(?:or)?d|pwd|credential|auth|api_?key|` +
+	`access_?key|private_?key|signing_?key)[A-Za-z0-9_.\-]*["']?\s*[:=]\s*["']?(?:bearer\s+)?` +
+	`((?:h1|sha1|sha256|sha384|sha512|md5)[:\-])?([A-Za-z0-9+/=_\-]+)`)
+
+// hexadecimal values are hashes like commit IDs and checksums rather than credentials
+var hexValue = regexp.MustCompile(`^[0-9A-Fa-f]+$`)
+
+// tokens outside of these bounds are skipped; longer ones are usually encoded data rather than credentials
+const (
+	minEntropyTokenLength = 20
+	maxEntropyTokenLength = 64
+)
+
+// Scanner finds possible secrets in text
+type Scanner struct {
+	rules     []Rule
+	allowlist []*regexp.Regexp
+}
+
+// NewScanner creates a scanner with the default rules and additional custom rules
+// Matches of any allowlist expression are never reported.
+func NewScanner(custom []Rule, allowlist []string) (*Scanner, error) {
+	scanner := &Scanner{}
+	for _, rule := range append(append([]Rule{}, DefaultRules...), custom...) {
+		expression, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid secret rule '%s': %s", rule.Name, err.Error())
+		}
+
+		rule.expression = expression
+		scanner.rules = append(scanner.rules, rule)
+	}
+
+	for _, pattern := range allowlist {
+		expression, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid secret allowlist entry '%s': %s", pattern, err.Error())
+		}
+
+		scanner.allowlist = append(scanner.allowlist, expression)
+	}
+
+	return scanner, nil
+}
+
+// ParsePolicy validates a policy provided by the user
+func ParsePolicy(policy string) (Policy, error) {
+	switch Policy(policy) {
+	case PolicyOff, PolicyBlock, PolicyWarn, PolicyRedact:
+		return Policy(policy), nil
+	default:
+		return "", fmt.Errorf("Invalid secrets policy '%s': expected off|block|warn|redact", policy)
+	}
+}
+
+// #endregion
+
+// ScanLine returns the possible secrets in a single line
+func (scanner *Scanner) ScanLine(line string, number int) []Finding {
+	var findings []Finding
+	for _, match := range scanner.matches(line) {
+		findings = append(findings, Finding{Rule: match.rule, Line: number, Match: mask(line[match.start:match.end])})
+	}
+
+	return findings
+}
+
+// RedactLine replaces all possible secrets in a line
+func (scanner *Scanner) RedactLine(line string) string {
+	matches := scanner.matches(line)
+	for i := len(matches) - 1; i >= 0; i-- {
+		match := matches[i]
+		line = line[:match.start] + fmt.Sprintf("[REDACTED %s]", match.rule) + line[match.end:]
+	}
+
+	return line
+}
+
+// Filter scans the input line by line while it is being read
+// Private keys are treated as a whole: all lines from the header to the end of the key, or to the end of the input if
+// the key is incomplete, are redacted.
+// With PolicyBlock, reading fails with a FoundError as soon as a possible secret is found; the lines before it have
+// been read already, so use Spool if nothing may be uploaded. With PolicyRedact, possible secrets are replaced.
+func (scanner *Scanner) Filter(input io.Reader, policy Policy) *FilterReader {
+	return &FilterReader{scanner: scanner, input: bufio.NewReader(input), policy: policy}
+}
+
+// Spool scans the input while writing it to a temporary file, so it can be uploaded after a confirmation without
+// being buffered in memory
+// The caller has to close and remove the returned file.
+func (scanner *Scanner) Spool(input io.Reader) (*os.File, []Finding, error) {
+	file, err := ioutil.TempFile("", "haste-spool-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error spooling input: %s", err.Error())
+	}
+
+	filter := scanner.Filter(input, PolicyWarn)
+	if _, err := io.Copy(file, filter); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, nil, err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, nil, fmt.Errorf("Error spooling input: %s", err.Error())
+	}
+
+	return file, filter.Findings, nil
+}
+
+// FilterReader applies a policy to its input line by line
+type FilterReader struct {
+	// Findings contains all possible secrets that have been found so far
+	Findings []Finding
+
+	scanner *Scanner
+	input   *bufio.Reader
+	policy  Policy
+	line    int
+	pending []byte
+	err     error
+	// privateKey is set while the lines of a private key are read
+	privateKey bool
+}
+
+func (filter *FilterReader) Read(p []byte) (int, error) {
+	for len(filter.pending) == 0 {
+		if filter.err != nil {
+			return 0, filter.err
+		}
+
+		line, err := filter.input.ReadString('\n')
+		if err != nil {
+			filter.err = err
+		}
+		if line == "" {
+			continue
+		}
+
+		filter.line++
+		if filter.privateKey {
+			// the rest of the key has already been reported with its header
+			filter.privateKey = !privateKeyEnd.MatchString(line)
+			if filter.policy == PolicyRedact {
+				line = redactedLine(line)
+			}
+			filter.pending = []byte(line)
+			continue
+		}
+
+		findings := filter.scanner.ScanLine(line, filter.line)
+		for _, finding := range findings {
+			if finding.Rule == privateKeyRuleName && !privateKeyEnd.MatchString(line) {
+				filter.privateKey = true
+			}
+		}
+		filter.Findings = append(filter.Findings, findings...)
+
+		if len(findings) > 0 && filter.policy == PolicyBlock {
+			filter.err = &FoundError{Findings: findings}
+			return 0, filter.err
+		}
+
+		if len(findings) > 0 && filter.policy == PolicyRedact {
+			line = filter.scanner.RedactLine(line)
+		}
+		filter.pending = []byte(line)
+	}
+
+	n := copy(p, filter.pending)
+	filter.pending = filter.pending[n:]
+
+	return n, nil
+}
+
+// #region Private
+
+type match struct {
+	rule       string
+	start, end int
+}
+
+func (scanner *Scanner) matches(line string) []match {
+	var matches []match
+	covered := func(start, end int) bool {
+		for _, existing := range matches {
+			if start < existing.end && end > existing.start {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, rule := range scanner.rules {
+		for _, location := range rule.expression.FindAllStringIndex(line, -1) {
+			if !covered(location[0], location[1]) && !scanner.allowed(line[location[0]:location[1]]) {
+				matches = append(matches, match{rule: rule.Name, start: location[0], end: location[1]})
+			}
+		}
+	}
+
+	for _, location := range entropyCandidates.FindAllStringSubmatchIndex(line, -1) {
+		start, end := location[4], location[5]
+		token := line[start:end]
+		if location[2] >= 0 || len(token) < minEntropyTokenLength || len(token) > maxEntropyTokenLength ||
+			hexValue.MatchString(token) {
+			continue
+		}
+
+		if isHighEntropy(token) && !covered(start, end) && !scanner.allowed(token) {
+			matches = append(matches, match{rule: entropyRuleName, start: start, end: end})
+		}
+	}
+
+	// sort by position, so redactions can be applied from the end of the line
+	for i := 1; i < len(matches); i++ {
+		for j := i; j > 0 && matches[j].start < matches[j-1].start; j-- {
+			matches[j], matches[j-1] = matches[j-1], matches[j]
+		}
+	}
+
+	return matches
+}
+
+// redactedLine replaces a line of a private key, keeping its line ending
+func redactedLine(line string) string {
+	content := strings.TrimRight(line, "\r\n")
+	return fmt.Sprintf("[REDACTED %s]", privateKeyRuleName) + line[len(content):]
+}
+
+func (scanner *Scanner) allowed(value string) bool {
+	for _, expression := range scanner.allowlist {
+		if expression.MatchString(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isHighEntropy reports whether a token looks like a randomly generated credential
+func isHighEntropy(token string) bool {
+	if !strings.ContainsAny(token, "0123456789") ||
+		strings.ToLower(token) == token || strings.ToUpper(token) == token {
+		return false
+	}
+
+	return entropy(token) >= entropyThreshold
+}
+
+// entropy calculates the Shannon entropy of a string in bits per character
+func entropy(value string) float64 {
+	counts := map[rune]int{}
+	for _, c := range value {
+		counts[c]++
+	}
+
+	var result float64
+	length := float64(len(value))
+	for _, count := range counts {
+		p := float64(count) / length
+		result -= p * math.Log2(p)
+	}
+
+	return result
+}
+
+func mask(value string) string {
+	if len(value) <= 8 {
+		return strings.Repeat("*", len(value))
+	}
+
+	return value[:4] + strings.Repeat("*", 4)
+}
+
+// #endregion
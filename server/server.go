@@ -7,6 +7,8 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+
+	"github.com/jagoe/haste-client-go/util"
 )
 
 // #region Setup
@@ -16,6 +18,22 @@ type HasteGetter interface {
 	Get(key string, client *http.Client) (string, error)
 }
 
+// ConditionalHasteGetter describes getting hastes that can be revalidated with HTTP validators
+type ConditionalHasteGetter interface {
+	GetConditional(key string, cached Validators, client *http.Client) (string, Validators, bool, error)
+}
+
+// Validators are the HTTP validators of a haste that are used to revalidate cached copies
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// HasteStreamer describes reading hastes while they are being downloaded
+type HasteStreamer interface {
+	Stream(key string, client *http.Client) (io.ReadCloser, error)
+}
+
 // HasteCreator describes creating hastes on a haste-server instance
 type HasteCreator interface {
 	Create(content io.Reader, client *http.Client) (string, error)
@@ -26,15 +44,20 @@ type HasteServer struct {
 	URL                      string `mapstructure:"server"`
 	ClientCertificatePath    string `mapstructure:"clientCert"`
 	ClientCertificateKeyPath string `mapstructure:"clientCertKey"`
+	MaxSize                  int    `mapstructure:"maxSize"`
 
 	// KeyPairLoader is not meant to be set manually; call HasteServer.Initialize() instead
 	KeyPairLoader X509KeyPairLoader
 }
 
+// DefaultMaxSize is the default maximum document length of a haste-server instance
+const DefaultMaxSize = 400000
+
 // MakeHasteServer creates a new instance of HasteServer
 func MakeHasteServer() HasteServer {
 	server := HasteServer{}
 	server.KeyPairLoader = TLSX509KeyPairLoader{}
+	server.MaxSize = DefaultMaxSize
 
 	return server
 }
@@ -43,32 +66,55 @@ func MakeHasteServer() HasteServer {
 
 // Get reads a haste from the provided server
 func (server HasteServer) Get(key string, client *http.Client) (string, error) {
-	tlsConfig, err := getTLSTransportConfig(server.ClientCertificatePath, server.ClientCertificateKeyPath, server.KeyPairLoader)
-	if err != nil {
-		return "", err
-	}
-
-	client.Transport = tlsConfig
+	haste, _, _, err := server.GetConditional(key, Validators{}, client)
+	return haste, err
+}
 
-	response, err := client.Get(fmt.Sprintf("%s/raw/%s", server.URL, key))
+// GetConditional reads a haste from the provided server unless it has not been modified since it was cached
+// If the server confirms that the cached version is still valid, notModified is true and the haste is empty.
+func (server HasteServer) GetConditional(key string, cached Validators, client *http.Client) (haste string,
+	validators Validators, notModified bool, err error) {
+	response, err := server.request(key, cached, client)
 	if err != nil {
-		return "", fmt.Errorf("Error retrieving haste: %s", err.Error())
+		return "", Validators{}, false, err
 	}
 
 	if response.Body != nil {
 		defer response.Body.Close()
 	}
 
+	if response.StatusCode == http.StatusNotModified && (cached.ETag != "" || cached.LastModified != "") {
+		return "", cached, true, nil
+	}
+
 	if response.StatusCode >= 300 {
-		return "", fmt.Errorf("Error retrieving document %s: %s", key, response.Status)
+		return "", Validators{}, false, statusError(key, response)
 	}
 
 	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return "", fmt.Errorf("Error retrieving haste: %s", err.Error())
+		return "", Validators{}, false, fmt.Errorf("Error retrieving haste: %s", err.Error())
+	}
+
+	validators = Validators{ETag: response.Header.Get("ETag"), LastModified: response.Header.Get("Last-Modified")}
+	return string(body), validators, false, nil
+}
+
+// Stream reads a haste from the provided server while it is being downloaded; the returned body has to be closed
+func (server HasteServer) Stream(key string, client *http.Client) (io.ReadCloser, error) {
+	response, err := server.request(key, Validators{}, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode >= 300 {
+		if response.Body != nil {
+			response.Body.Close()
+		}
+		return nil, statusError(key, response)
 	}
 
-	return string(body), nil
+	return response.Body, nil
 }
 
 type createHasteResponse struct {
@@ -97,7 +143,11 @@ func (server HasteServer) Create(content io.Reader, client *http.Client) (string
 	decoder := json.NewDecoder(response.Body)
 	decoder.DisallowUnknownFields()
 	if err := decoder.Decode(&haste); err != nil {
-		return "", fmt.Errorf("Error when retrieving the haste key: %s", err.Error())
+		return "", fmt.Errorf("Error when retrieving the haste key: %s", util.SanitizeTerminal(err.Error()))
+	}
+
+	if util.SanitizeTerminal(haste.Key) != haste.Key {
+		return "", fmt.Errorf("Error when retrieving the haste key: invalid key %s", util.SanitizeTerminal(haste.Key))
 	}
 
 	return haste.Key, nil
@@ -105,6 +155,41 @@ func (server HasteServer) Create(content io.Reader, client *http.Client) (string
 
 // #region Private
 
+// request requests the raw content of a haste, revalidating a cached version if validators are provided
+func (server HasteServer) request(key string, cached Validators, client *http.Client) (*http.Response, error) {
+	tlsConfig, err := getTLSTransportConfig(server.ClientCertificatePath, server.ClientCertificateKeyPath, server.KeyPairLoader)
+	if err != nil {
+		return nil, err
+	}
+
+	client.Transport = tlsConfig
+
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/raw/%s", server.URL, key), nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving haste: %s", err.Error())
+	}
+
+	if cached.ETag != "" {
+		request.Header.Set("If-None-Match", cached.ETag)
+	}
+	if cached.LastModified != "" {
+		request.Header.Set("If-Modified-Since", cached.LastModified)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving haste: %s", err.Error())
+	}
+
+	return response, nil
+}
+
+// statusError describes a failed request; the status text is provided by the server and may contain terminal escape
+// sequences
+func statusError(key string, response *http.Response) error {
+	return fmt.Errorf("Error retrieving document %s: %s", key, util.SanitizeTerminal(response.Status))
+}
+
 // #region Test types & methods
 // GetTLSTransportConfig prepares a TLS transport config with the provided certificate and key
 // If certificate or key are not specified, an empty (but usable) configuration will be returned.
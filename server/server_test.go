@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/tls"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -113,6 +114,36 @@ func TestGet(t *testing.T) {
 	})
 }
 
+func TestStream(t *testing.T) {
+	t.Run("should return an error if the GET request returns an error response", func(t *testing.T) {
+		server, endpoint := prepareTest(TestSettings{ResponseCode: 404})
+		defer endpoint.Close()
+
+		_, err := server.Stream("abcdef", endpoint.Client())
+
+		expectedError := "Error retrieving document abcdef: 404 Not Found"
+		if err == nil || err.Error() != expectedError {
+			t.Fatalf("Should have returned '%s' as error, got '%v'", expectedError, err)
+		}
+	})
+
+	t.Run("should return the response body", func(t *testing.T) {
+		server, endpoint := prepareTest(TestSettings{ResponseBody: "Streamed haste"})
+		defer endpoint.Close()
+
+		body, err := server.Stream("abcdef", endpoint.Client())
+		if err != nil {
+			t.Fatalf("Should not have returned an error: %s", err.Error())
+		}
+		defer body.Close()
+
+		content, _ := ioutil.ReadAll(body)
+		if string(content) != "Streamed haste|/raw/abcdef" {
+			t.Fatalf("Unexpected response body '%s'", content)
+		}
+	})
+}
+
 func TestCreate(t *testing.T) {
 	t.Run("should return an error if the transport config cannot be set", func(t *testing.T) {
 		configError := "Expected error"
@@ -147,6 +178,22 @@ func TestCreate(t *testing.T) {
 		}
 	})
 
+	t.Run("should return an error if the key contains control characters", func(t *testing.T) {
+		expectedError := `Error when retrieving the haste key: invalid key \x1b]0;title\x07`
+		server, endpoint := prepareTest(TestSettings{ResponseBody: `{"key": "\u001b]0;title\u0007"}`})
+		defer endpoint.Close()
+
+		_, err := server.Create(bytes.NewBufferString("content"), endpoint.Client())
+
+		if err == nil {
+			t.Fatalf("Should have returned an error")
+		}
+
+		if err.Error() != expectedError {
+			t.Fatalf("Should have returned '%s' as error, got '%s'", expectedError, err.Error())
+		}
+	})
+
 	t.Run("should return the key of the generated haste", func(t *testing.T) {
 		key := "abcdef"
 		server, endpoint := prepareTest(TestSettings{ResponseBody: fmt.Sprintf(`{"key": "%s"}`, key)})
@@ -163,3 +210,47 @@ func TestCreate(t *testing.T) {
 		}
 	})
 }
+
+func TestGetConditional(t *testing.T) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 01 Jun 2020 10:00:00 GMT")
+		w.Write([]byte("Cool haste, bro!"))
+	}))
+	defer endpoint.Close()
+	server := HasteServer{URL: endpoint.URL, KeyPairLoader: FakeKeyPairLoader{}}
+
+	t.Run("should return the haste and its validators", func(t *testing.T) {
+		haste, validators, notModified, err := server.GetConditional("abcdef", Validators{}, endpoint.Client())
+
+		if err != nil {
+			t.Fatalf("Should not have returned an error: %s", err.Error())
+		}
+
+		if haste != "Cool haste, bro!" || notModified {
+			t.Fatalf("Expected the haste to be returned, got '%s'", haste)
+		}
+
+		if validators.ETag != `"v1"` || validators.LastModified != "Mon, 01 Jun 2020 10:00:00 GMT" {
+			t.Fatalf("Unexpected validators: %+v", validators)
+		}
+	})
+
+	t.Run("should report unmodified hastes", func(t *testing.T) {
+		cached := Validators{ETag: `"v1"`}
+		haste, validators, notModified, err := server.GetConditional("abcdef", cached, endpoint.Client())
+
+		if err != nil {
+			t.Fatalf("Should not have returned an error: %s", err.Error())
+		}
+
+		if !notModified || haste != "" || validators != cached {
+			t.Fatalf("Expected the haste not to be modified, got '%s' and %+v", haste, validators)
+		}
+	})
+}
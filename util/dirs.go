@@ -0,0 +1,44 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// AppName is the name of the directories the client stores its data in
+const AppName = "haste-client-go"
+
+// DataDir returns the directory for persistent data, following the XDG base directory specification
+func DataDir() (string, error) {
+	return appDir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+}
+
+// CacheDir returns the directory for cached data, following the XDG base directory specification
+func CacheDir() (string, error) {
+	return appDir("XDG_CACHE_HOME", ".cache")
+}
+
+// #region Private
+
+func appDir(variable string, fallback string) (string, error) {
+	if dir := os.Getenv(variable); dir != "" {
+		return filepath.Join(dir, AppName), nil
+	}
+
+	if dir := os.Getenv("LOCALAPPDATA"); dir != "" && runtime.GOOS == "windows" {
+		return filepath.Join(dir, AppName), nil
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", fmt.Errorf("Error finding home directory: %s", err.Error())
+	}
+
+	return filepath.Join(home, fallback, AppName), nil
+}
+
+// #endregion
@@ -0,0 +1,135 @@
+package util
+
+import (
+	"regexp"
+	"strings"
+)
+
+// IgnoreRules matches paths against patterns in the format of .gitignore files
+type IgnoreRules struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	base    string
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Add parses the content of an ignore file located in base, a slash-separated path relative to the walked root
+func (rules *IgnoreRules) Add(base string, content string) {
+	base = strings.Trim(base, "/")
+	if base == "." {
+		base = ""
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimRight(line, " ")
+
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, "\\")
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+
+		// patterns containing a slash are relative to the ignore file, others match at any depth
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+
+		expression := globToRegexp(line)
+		if !anchored {
+			expression = "(.*/)?" + expression
+		}
+
+		pattern, err := regexp.Compile("^" + expression + "$")
+		if err != nil {
+			continue
+		}
+		rule.pattern = pattern
+
+		rules.rules = append(rules.rules, rule)
+	}
+}
+
+// Match reports whether a slash-separated path relative to the walked root is ignored
+// The last matching rule wins, so negated patterns can re-include paths.
+func (rules *IgnoreRules) Match(path string, isDir bool) bool {
+	ignored := false
+	for _, rule := range rules.rules {
+		relative := path
+		if rule.base != "" {
+			if !strings.HasPrefix(path, rule.base+"/") {
+				continue
+			}
+			relative = strings.TrimPrefix(path, rule.base+"/")
+		}
+
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		if rule.pattern.MatchString(relative) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
+
+// #region Private
+
+func globToRegexp(glob string) string {
+	var expression strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			expression.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			expression.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			expression.WriteString(".*")
+			i++
+		case c == '*':
+			expression.WriteString("[^/]*")
+		case c == '?':
+			expression.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				expression.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expression.WriteString("[" + class + "]")
+			i += end
+		case c == '\\' && i+1 < len(glob):
+			i++
+			expression.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return expression.String()
+}
+
+// #endregion
@@ -0,0 +1,34 @@
+package util
+
+import "testing"
+
+func TestIgnoreRules(t *testing.T) {
+	rules := &IgnoreRules{}
+	rules.Add("", "# comment\n*.log\n!keep.log\nbuild/\n/root.txt\ndocs/**/*.tmp\n")
+	rules.Add("sub", "local.txt\n")
+
+	tests := []struct {
+		title   string
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"Plain file", "main.go", false, false},
+		{"Wildcard", "app.log", false, true},
+		{"Wildcard in subdirectory", "a/b/app.log", false, true},
+		{"Negated pattern", "keep.log", false, false},
+		{"Directory pattern", "build", true, true},
+		{"Directory pattern on file", "build", false, false},
+		{"Anchored pattern", "root.txt", false, true},
+		{"Anchored pattern in subdirectory", "a/root.txt", false, false},
+		{"Double asterisk", "docs/a/b/c.tmp", false, true},
+		{"Nested ignore file", "sub/local.txt", false, true},
+		{"Nested ignore file outside of its directory", "local.txt", false, false},
+	}
+
+	for _, test := range tests {
+		if ignored := rules.Match(test.path, test.isDir); ignored != test.ignored {
+			t.Errorf("%s: Expected '%s' to be ignored: %t", test.title, test.path, test.ignored)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package util
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// HasteLink is a link to a haste that has been found in text
+type HasteLink struct {
+	URL    string
+	Server string
+	Key    string
+}
+
+var (
+	urlCandidate = regexp.MustCompile(`https?://[^\s<>"'()\[\]{}|\\^` + "`" + `]+`)
+	// keyPattern matches haste keys with an optional extension for highlighting
+	keyPattern = regexp.MustCompile(`^\w+(\.\w+)?$`)
+	// generatedKeyPattern only matches generated keys, so links of unknown hosts are less likely to be false positives
+	generatedKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]{4,}(\.\w+)?$`)
+)
+
+// FindHasteLinks finds all links to hastes in a text, in order of their first appearance
+// Links are only accepted for the known servers, which may include a path prefix, unless anyHost is set. Raw links
+// (/raw/<key>) are recognized as well; links to the same haste are only returned once.
+func FindHasteLinks(text string, servers []string, anyHost bool) []HasteLink {
+	var links []HasteLink
+	seen := map[string]bool{}
+
+	for _, candidate := range urlCandidate.FindAllString(text, -1) {
+		candidate = strings.TrimRight(candidate, ".,;:!?*_~")
+
+		link, ok := matchHasteLink(candidate, servers, anyHost)
+		if !ok {
+			continue
+		}
+
+		id := strings.ToLower(link.Server) + "/" + strings.SplitN(link.Key, ".", 2)[0]
+		if seen[id] {
+			continue
+		}
+
+		seen[id] = true
+		links = append(links, link)
+	}
+
+	return links
+}
+
+// #region Private
+
+func matchHasteLink(candidate string, servers []string, anyHost bool) (HasteLink, bool) {
+	parsed, ok := parseHTTPURL(candidate)
+	if !ok {
+		return HasteLink{}, false
+	}
+
+	for _, server := range servers {
+		serverURL, err := url.Parse(strings.TrimSuffix(server, "/"))
+		if err != nil || !strings.EqualFold(serverURL.Host, parsed.Host) {
+			continue
+		}
+
+		if base, key, ok := splitHasteURL(parsed, serverURL.Path, keyPattern); ok {
+			return HasteLink{URL: candidate, Server: base, Key: key}, true
+		}
+	}
+
+	if !anyHost {
+		return HasteLink{}, false
+	}
+
+	base, key, ok := splitHasteURL(parsed, "", generatedKeyPattern)
+	if !ok {
+		return HasteLink{}, false
+	}
+
+	return HasteLink{URL: candidate, Server: base, Key: key}, true
+}
+
+// parseHTTPURL parses an absolute HTTP(S) URL; query and fragment are ignored by the haste grammar
+func parseHTTPURL(candidate string) (*url.URL, bool) {
+	parsed, err := url.Parse(candidate)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, false
+	}
+
+	return parsed, true
+}
+
+// splitHasteURL splits a URL into the server, including its path prefix, and the key of a haste: <prefix>/<key> or
+// <prefix>/raw/<key>
+func splitHasteURL(parsed *url.URL, prefix string, pattern *regexp.Regexp) (string, string, bool) {
+	if !strings.HasPrefix(parsed.Path, prefix+"/") {
+		return "", "", false
+	}
+
+	key, ok := hasteKey(strings.TrimPrefix(parsed.Path, prefix+"/"), pattern)
+	if !ok {
+		return "", "", false
+	}
+
+	return parsed.Scheme + "://" + parsed.Host + prefix, key, true
+}
+
+// hasteKey extracts the key of a haste path relative to its server: <key> or raw/<key>
+func hasteKey(path string, pattern *regexp.Regexp) (string, bool) {
+	path = strings.TrimPrefix(path, "raw/")
+	if !pattern.MatchString(path) {
+		return "", false
+	}
+
+	return path, true
+}
+
+// #endregion
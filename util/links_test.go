@@ -0,0 +1,41 @@
+package util
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFindHasteLinks(t *testing.T) {
+	text := `10:02 <anna> see https://hastebin.com/ogoquyocaq.go, and the raw one: https://hastebin.com/raw/ogoquyocaq
+10:03 <ben> mine is at (http://paste.example.com/haste/ebuqokorad#L10) - not https://github.com/jagoe/haste-client-go
+10:04 <carl> https://other.example.org/abcdefghij and https://hastebin.com/about/team`
+
+	t.Run("should find links of known servers", func(t *testing.T) {
+		links := FindHasteLinks(text, []string{"https://hastebin.com", "https://paste.example.com/haste/"}, false)
+
+		expected := []HasteLink{
+			{URL: "https://hastebin.com/ogoquyocaq.go", Server: "https://hastebin.com", Key: "ogoquyocaq.go"},
+			{URL: "http://paste.example.com/haste/ebuqokorad#L10", Server: "http://paste.example.com/haste", Key: "ebuqokorad"},
+		}
+		if fmt.Sprint(links) != fmt.Sprint(expected) {
+			t.Errorf("Expected %v, got %v", expected, links)
+		}
+	})
+
+	t.Run("should find links of any host", func(t *testing.T) {
+		links := FindHasteLinks(text, nil, true)
+
+		if len(links) != 2 || links[1].Key != "abcdefghij" {
+			t.Errorf("Expected the links of all hosts, got %v", links)
+		}
+	})
+
+	t.Run("should split links like ParseURL", func(t *testing.T) {
+		for _, link := range FindHasteLinks(text, []string{"https://hastebin.com"}, true) {
+			if server, key := ParseURL(link.URL); server != link.Server || key != link.Key {
+				t.Errorf("Expected ParseURL to split %s into (%s, %s), got (%s, %s)", link.URL, link.Server, link.Key,
+					server, key)
+			}
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	lockRetryInterval = 20 * time.Millisecond
+	// a lock that is older than this has most likely been left behind by a crashed process
+	staleLockAge = 30 * time.Second
+)
+
+// LockFile acquires an exclusive lock for the provided file by creating a lock file next to it
+// The lock file is created atomically, so it works across processes and platforms. Call the returned function to
+// release the lock.
+func LockFile(path string, timeout time.Duration) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(timeout)
+
+	for {
+		lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			fmt.Fprintf(lock, "%d", os.Getpid())
+			lock.Close()
+
+			return func() { os.Remove(lockPath) }, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("Error locking %s: %s", path, err.Error())
+		}
+
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > staleLockAge {
+			removeStaleLock(lockPath, info)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Error locking %s: timed out waiting for %s", path, lockPath)
+		}
+
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// #region Private
+
+// removeStaleLock removes a lock file only if it still is the stale one that has been stat'ed
+// Several processes may find the same stale lock; the lock file is renamed before it is removed, so a process that is
+// late cannot remove a lock another process has taken in the meantime. Such a lock is put back instead.
+func removeStaleLock(lockPath string, stale os.FileInfo) {
+	claimed := fmt.Sprintf("%s.%d.stale", lockPath, os.Getpid())
+	if err := os.Rename(lockPath, claimed); err != nil {
+		// another process has removed the stale lock already
+		return
+	}
+
+	if info, err := os.Stat(claimed); err == nil && (!os.SameFile(info, stale) || !info.ModTime().Equal(stale.ModTime())) {
+		// fails if yet another process has taken the lock, which then is held by that process
+		os.Link(claimed, lockPath)
+	}
+
+	os.Remove(claimed)
+}
+
+// #endregion
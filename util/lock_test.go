@@ -0,0 +1,67 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "haste-test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+
+	unlock, err := LockFile(path, time.Second)
+	if err != nil {
+		t.Fatalf("Should not have returned error: %s", err.Error())
+	}
+
+	if _, err := LockFile(path, 50*time.Millisecond); err == nil {
+		t.Fatalf("Expected a second lock to time out")
+	}
+
+	unlock()
+
+	unlock, err = LockFile(path, time.Second)
+	if err != nil {
+		t.Fatalf("Expected the lock to be released: %s", err.Error())
+	}
+	unlock()
+
+	stale := time.Now().Add(-time.Hour)
+	ioutil.WriteFile(path+".lock", nil, 0600)
+	os.Chtimes(path+".lock", stale, stale)
+	if unlock, err = LockFile(path, 50*time.Millisecond); err != nil {
+		t.Fatalf("Expected a stale lock to be removed: %s", err.Error())
+	}
+	unlock()
+}
+
+func TestRemoveStaleLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "file.lock")
+	stale := time.Now().Add(-time.Hour)
+	ioutil.WriteFile(lockPath, nil, 0600)
+	os.Chtimes(lockPath, stale, stale)
+	info, _ := os.Stat(lockPath)
+
+	// another process removes the stale lock and takes a new one before this one gets to remove it
+	os.Remove(lockPath)
+	ioutil.WriteFile(lockPath, []byte("fresh"), 0600)
+
+	removeStaleLock(lockPath, info)
+	if content, err := ioutil.ReadFile(lockPath); err != nil || string(content) != "fresh" {
+		t.Fatalf("Expected the lock of the other process to be kept, got %q (%v)", content, err)
+	}
+
+	info, _ = os.Stat(lockPath)
+	removeStaleLock(lockPath, info)
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Error("Expected the stale lock to be removed")
+	}
+
+	if files, _ := ioutil.ReadDir(filepath.Dir(lockPath)); len(files) != 0 {
+		t.Errorf("Expected no leftover files, got %d", len(files))
+	}
+}
@@ -0,0 +1,98 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// IsTerminal reports whether a writer is a terminal
+func IsTerminal(out io.Writer) bool {
+	file, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// SanitizeTerminal escapes control characters that a terminal would interpret, e.g. ANSI and OSC escape sequences that
+// change the title, move the cursor or write to the clipboard
+// Line feeds, tabs and carriage returns that end a line are kept.
+func SanitizeTerminal(text string) string {
+	var sanitized strings.Builder
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+
+		switch {
+		case r == utf8.RuneError && size == 1:
+			// invalid UTF-8; bytes that are C1 control characters in 8-bit terminals are escaped
+			if text[i] >= 0x80 && text[i] <= 0x9f {
+				fmt.Fprintf(&sanitized, `\x%02x`, text[i])
+			} else {
+				sanitized.WriteByte(text[i])
+			}
+		case r == '\n' || r == '\t' || (r == '\r' && strings.HasPrefix(text[i+1:], "\n")):
+			sanitized.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&sanitized, `\x%02x`, r)
+		case r >= 0x80 && r <= 0x9f:
+			fmt.Fprintf(&sanitized, `\u%04x`, r)
+		default:
+			sanitized.WriteString(text[i : i+size])
+		}
+
+		i += size
+	}
+
+	return sanitized.String()
+}
+
+// TerminalWriter sanitizes everything that is written to a terminal
+// An incomplete UTF-8 character or a carriage return at the end of a write is held back until the next write or Flush,
+// so characters and CRLF line endings that are split across writes are not mangled. Other sequences that are split
+// across writes are escaped in parts.
+type TerminalWriter struct {
+	Out io.Writer
+
+	pending []byte
+}
+
+func (writer *TerminalWriter) Write(p []byte) (int, error) {
+	content := append(writer.pending, p...)
+	complete := len(content)
+	for i := len(content) - 1; i >= 0 && i >= len(content)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(content[i]) {
+			if !utf8.FullRune(content[i:]) {
+				complete = i
+			}
+			break
+		}
+	}
+	if complete == len(content) && complete > 0 && content[complete-1] == '\r' {
+		// a line feed in the next write keeps it
+		complete--
+	}
+
+	writer.pending = append([]byte{}, content[complete:]...)
+	if _, err := io.WriteString(writer.Out, SanitizeTerminal(string(content[:complete]))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Flush writes an incomplete UTF-8 character that has been held back
+func (writer *TerminalWriter) Flush() error {
+	if len(writer.pending) == 0 {
+		return nil
+	}
+
+	pending := writer.pending
+	writer.pending = nil
+	_, err := io.WriteString(writer.Out, SanitizeTerminal(string(pending)))
+	return err
+}
@@ -0,0 +1,79 @@
+package util
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSanitizeTerminal(t *testing.T) {
+	cases := map[string]string{
+		"plain text\n\tindented 🙃":     "plain text\n\tindented 🙃",
+		"windows\r\nline\r\n":          "windows\r\nline\r\n",
+		"\x1b[31mred\x1b[0m":           `\x1b[31mred\x1b[0m`,
+		"\x1b]0;fake title\x07":        `\x1b]0;fake title\x07`,
+		"fake\rprompt $ ":              `fake\x0dprompt $ `,
+		"c1 \u009b31m":                 `c1 \u009b31m`,
+		"8-bit \x9b31m":                `8-bit \x9b31m`,
+		"latin-1 caf\xe9 stays":        "latin-1 caf\xe9 stays",
+		"delete\x7f and backspace\x08": `delete\x7f and backspace\x08`,
+	}
+
+	for text, expected := range cases {
+		if sanitized := SanitizeTerminal(text); sanitized != expected {
+			t.Errorf("Expected %q to be sanitized to %q, got %q", text, expected, sanitized)
+		}
+	}
+}
+
+func TestTerminalWriter(t *testing.T) {
+	buffer := bytes.NewBufferString("")
+	writer := &TerminalWriter{Out: buffer}
+
+	n, err := writer.Write([]byte("\x1b[2Jcleared"))
+	if err != nil || n != 11 {
+		t.Errorf("Expected all 11 bytes to be written, got %d, %v", n, err)
+	}
+
+	if buffer.String() != `\x1b[2Jcleared` {
+		t.Errorf("Expected the escape sequence to be escaped, got %q", buffer.String())
+	}
+}
+
+func TestTerminalWriterWithSplitCharacter(t *testing.T) {
+	buffer := bytes.NewBufferString("")
+	writer := &TerminalWriter{Out: buffer}
+
+	// "ä€" is split in the middle of both characters
+	content := []byte("ä€")
+	for _, part := range [][]byte{content[:1], content[1:3], content[3:]} {
+		if n, err := writer.Write(part); err != nil || n != len(part) {
+			t.Fatalf("Expected all %d bytes to be written, got %d, %v", len(part), n, err)
+		}
+	}
+	if buffer.String() != "ä€" {
+		t.Errorf(`Expected "ä€", got %q`, buffer.String())
+	}
+
+	writer.Write(content[:1])
+	if buffer.String() != "ä€" {
+		t.Errorf("Expected an incomplete character to be held back, got %q", buffer.String())
+	}
+	if err := writer.Flush(); err != nil || buffer.String() != "ä€\xc3" {
+		t.Errorf("Expected Flush to write the incomplete character, got %q (%v)", buffer.String(), err)
+	}
+}
+
+func TestTerminalWriterWithSplitLineEnding(t *testing.T) {
+	buffer := bytes.NewBufferString("")
+	writer := &TerminalWriter{Out: buffer}
+
+	writer.Write([]byte("first\r"))
+	writer.Write([]byte("\nsecond\r"))
+	if buffer.String() != "first\r\nsecond" {
+		t.Errorf("Expected a CRLF split across writes to be kept, got %q", buffer.String())
+	}
+
+	if err := writer.Flush(); err != nil || buffer.String() != `first`+"\r\n"+`second\x0d` {
+		t.Errorf("Expected Flush to escape a trailing carriage return, got %q (%v)", buffer.String(), err)
+	}
+}
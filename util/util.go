@@ -1,29 +1,34 @@
 package util
 
 import (
-	"regexp"
 	"strings"
 )
 
 // ParseURL takes a possible URL and splits it into host and path or returns empty strings if the parameter is not a URL
+// Raw URLs (/raw/<key>) are split into host and key as well. Fragments, e.g. #L10-L40 anchors, are ignored. Links found
+// by FindHasteLinks follow the same grammar.
 func ParseURL(possibleURL string) (string, string) {
-	r := regexp.MustCompile(`(https?:\/\/.*?)\/([\w\.]+)(.*)`)
-	match := r.FindStringSubmatch(possibleURL)
-
-	if len(match) < 3 {
+	parsed, ok := parseHTTPURL(possibleURL)
+	if !ok {
 		// not a valid hastebin URL
 		return "", ""
 	}
 
-	if match[2] == "" {
-		// empty path
+	server, key, ok := splitHasteURL(parsed, "", keyPattern)
+	if !ok {
+		// empty path or not a key
 		return "", ""
 	}
 
-	if strings.Contains(match[3], "/") {
-		// no a key
-		return "", ""
+	return server, key
+}
+
+// SplitAnchor splits a haste key or URL into the key or URL and its fragment, e.g. L10-L40 of a line anchor
+func SplitAnchor(ref string) (string, string) {
+	index := strings.Index(ref, "#")
+	if index < 0 {
+		return ref, ""
 	}
 
-	return match[1], match[2]
+	return ref[:index], ref[index+1:]
 }
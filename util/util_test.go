@@ -18,14 +18,37 @@ func TestParseURL(t *testing.T) {
 		{"HTTPS URL with path", "https://hastebin/abcdef", "https://hastebin", "abcdef"},
 		{"Valid URL with query", "https://hastebin/abcdef?q=s", "https://hastebin", "abcdef"},
 		{"Valid URL with language-specific key", "https://hastebin/abcdef.yaml", "https://hastebin", "abcdef.yaml"},
+		{"Raw URL", "https://hastebin/raw/abcdef", "https://hastebin", "abcdef"},
+		{"URL with line anchor", "https://hastebin/abcdef.go#L10-L40", "https://hastebin", "abcdef.go"},
+		{"URL with path in anchor", "https://hastebin/abcdef#a/b", "https://hastebin", "abcdef"},
 	}
 
 	for _, test := range tests {
 		server, key := ParseURL(test.url)
 
-		if server != test.server && key != test.key {
+		if server != test.server || key != test.key {
 			t.Errorf(`%s: Parsing URL '%s' should have resulted in (server, key) = ("%s", "%s"), got ("%s", "%s")`,
 				test.title, test.url, test.server, test.key, server, key)
 		}
 	}
 }
+
+func TestSplitAnchor(t *testing.T) {
+	tests := []struct {
+		ref    string
+		base   string
+		anchor string
+	}{
+		{"abcdef", "abcdef", ""},
+		{"abcdef#L10-L40", "abcdef", "L10-L40"},
+		{"https://hastebin/abcdef.go#L10", "https://hastebin/abcdef.go", "L10"},
+	}
+
+	for _, test := range tests {
+		base, anchor := SplitAnchor(test.ref)
+		if base != test.base || anchor != test.anchor {
+			t.Errorf(`Splitting '%s' should have resulted in ("%s", "%s"), got ("%s", "%s")`, test.ref, test.base,
+				test.anchor, base, anchor)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package viewer
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"mime"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jagoe/haste-client-go/client"
+	"github.com/jagoe/haste-client-go/highlight"
+)
+
+// Viewer serves a single haste as a web page with highlighting, line anchors and a raw download
+// The page is only served below a random path, so other local users and websites cannot guess its address.
+type Viewer struct {
+	document  *client.Document
+	title     string
+	extension string
+	token     string
+}
+
+// New creates a viewer for a fetched haste; the extension determines the highlighted language
+func New(document *client.Document, title string, extension string) (*Viewer, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("Error creating viewer: %s", err.Error())
+	}
+
+	return &Viewer{document: document, title: title, extension: extension, token: hex.EncodeToString(token)}, nil
+}
+
+// Path returns the path the page is served at
+func (viewer *Viewer) Path() string {
+	return fmt.Sprintf("/%s/", viewer.token)
+}
+
+// ServeHTTP serves the page at Path and the haste at Path/raw
+func (viewer *Viewer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch r.URL.Path {
+	case viewer.Path():
+		viewer.servePage(w)
+	case viewer.Path() + "raw":
+		viewer.serveRaw(w, r.URL.Query().Get("inline") != "")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Serve serves a handler until the timeout expires or stop is closed
+// Requests are only accepted for the address of the listener, which prevents DNS rebinding attacks.
+func Serve(listener net.Listener, handler http.Handler, timeout time.Duration, stop <-chan struct{}) error {
+	server := &http.Server{Handler: hostGuard(listener.Addr().String(), handler), ReadHeaderTimeout: 10 * time.Second}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-done:
+		return fmt.Errorf("Error serving haste: %s", err.Error())
+	case <-time.After(timeout):
+	case <-stop:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+
+	return nil
+}
+
+// #region Private
+
+// script highlights the lines selected by an anchor like #L10-L40; shift-clicking a line number extends the selection
+const script = `
+var selected = [];
+function select() {
+	selected.forEach(function (row) { row.classList.remove("selected"); });
+	selected = [];
+	var match = /^#L(\d+)(?:-L(\d+))?$/.exec(location.hash);
+	if (!match) return;
+	var from = +match[1], to = +(match[2] || match[1]);
+	for (var n = from; n <= to; n++) {
+		var row = document.getElementById("L" + n);
+		if (row) { row.classList.add("selected"); selected.push(row); }
+	}
+	if (selected.length) selected[0].scrollIntoView({block: "center"});
+}
+document.addEventListener("click", function (event) {
+	var link = event.target.closest("a.hl-line");
+	var match = /^#L(\d+)/.exec(location.hash);
+	if (!link || !event.shiftKey || !match) return;
+	event.preventDefault();
+	var from = +match[1], to = +link.hash.slice(2);
+	location.hash = "#L" + Math.min(from, to) + "-L" + Math.max(from, to);
+});
+window.addEventListener("hashchange", select);
+select();
+`
+
+var (
+	scriptHash = sha256.Sum256([]byte(script))
+	pagePolicy = fmt.Sprintf("default-src 'none'; style-src 'unsafe-inline'; img-src 'self'; script-src 'sha256-%s'",
+		base64.StdEncoding.EncodeToString(scriptHash[:]))
+
+	page = template.Must(template.New("page").Funcs(template.FuncMap{
+		"inc": func(i int) int { return i + 1 },
+	}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+:root { color-scheme: light dark; --bg: #fff; --fg: #24292f; --muted: #6e7781; --mark: #fff8c5;
+	--keyword: #8250df; --string: #0a3069; --comment: #6e7781; --number: #0550ae; }
+@media (prefers-color-scheme: dark) { :root { --bg: #0d1117; --fg: #c9d1d9; --muted: #8b949e; --mark: #3b3218;
+	--keyword: #d2a8ff; --string: #a5d6ff; --comment: #8b949e; --number: #79c0ff; } }
+body { margin: 0; background: var(--bg); color: var(--fg); font-family: sans-serif; }
+header { display: flex; justify-content: space-between; gap: 1em; padding: .5em 1em;
+	border-bottom: 1px solid var(--muted); }
+header span { overflow-wrap: anywhere; }
+a { color: var(--number); }
+table { border-collapse: collapse; font: 13px/1.5 monospace; }
+td { padding: 0 1em; vertical-align: top; white-space: pre; }
+tr.selected { background: var(--mark); }
+a.hl-line { color: var(--muted); text-decoration: none; display: block; text-align: right; }
+.hl-keyword { color: var(--keyword); } .hl-string { color: var(--string); }
+.hl-comment { color: var(--comment); font-style: italic; } .hl-number { color: var(--number); }
+.binary { padding: 1em; } .binary img { max-width: 100%; }
+</style>
+</head>
+<body>
+<header><span>{{.Title}}</span><a href="raw" download>Download</a></header>
+{{- if .Envelope}}
+<div class="binary">
+<p>{{with .Envelope.Filename}}{{.}}, {{end}}{{.Envelope.MIME}}, {{.Envelope.Size}} bytes</p>
+{{- if .Image}}
+<img src="raw?inline=1" alt="{{.Envelope.Filename}}">
+{{- end}}
+</div>
+{{- else}}
+<table>
+{{- range $i, $line := .Lines}}
+<tr id="L{{inc $i}}"><td><a class="hl-line" href="#L{{inc $i}}">{{inc $i}}</a></td><td>{{$line}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+<script>{{.Script}}</script>
+</body>
+</html>
+`))
+)
+
+func (viewer *Viewer) servePage(w http.ResponseWriter) {
+	data := struct {
+		Title    string
+		Envelope *client.Envelope
+		Image    bool
+		Lines    []template.HTML
+		Script   template.JS
+	}{Title: viewer.title, Envelope: viewer.document.Envelope, Script: template.JS(script)}
+
+	if data.Envelope != nil {
+		data.Image = strings.HasPrefix(data.Envelope.MIME, "image/") && data.Envelope.MIME != "image/svg+xml"
+	} else {
+		text := strings.TrimSuffix(string(viewer.document.Content), "\n")
+		// highlight.HTML escapes the haste, so every line is safe to embed
+		for _, line := range strings.Split(highlight.HTML(text, viewer.extension), "\n") {
+			data.Lines = append(data.Lines, template.HTML(line))
+		}
+	}
+
+	w.Header().Set("Content-Security-Policy", pagePolicy)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := page.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (viewer *Viewer) serveRaw(w http.ResponseWriter, inline bool) {
+	filename := filepath.Base(viewer.document.Key)
+	contentType := "text/plain; charset=utf-8"
+	if envelope := viewer.document.Envelope; envelope != nil {
+		contentType = envelope.MIME
+		if envelope.Filename != "" {
+			filename = filepath.Base(envelope.Filename)
+		}
+	}
+
+	disposition := "attachment"
+	if inline {
+		disposition = "inline"
+	}
+
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filename}))
+	w.Write(viewer.document.Content)
+}
+
+func hostGuard(address string, handler http.Handler) http.Handler {
+	_, port, _ := net.SplitHostPort(address)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, requestPort, err := net.SplitHostPort(r.Host)
+		if err != nil || requestPort != port || (host != "localhost" && net.ParseIP(host) == nil) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// #endregion
@@ -0,0 +1,139 @@
+package viewer
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jagoe/haste-client-go/client"
+)
+
+func TestViewerPage(t *testing.T) {
+	viewer, err := New(&client.Document{Key: "abc.go", Content: []byte("func main() {}\n<script>\n")}, "haste <abc>", "go")
+	if err != nil {
+		t.Fatalf("Error creating viewer: %s", err.Error())
+	}
+
+	response := httptest.NewRecorder()
+	viewer.ServeHTTP(response, httptest.NewRequest("GET", viewer.Path(), nil))
+	body := response.Body.String()
+
+	expected := []string{
+		`<title>haste &lt;abc&gt;</title>`,
+		`<tr id="L1"><td><a class="hl-line" href="#L1">1</a></td><td><span class="hl-keyword">func</span> main() {}</td></tr>`,
+		`<tr id="L2"><td><a class="hl-line" href="#L2">2</a></td><td>&lt;script&gt;</td></tr>`,
+		"<script>" + script + "</script>",
+	}
+	for _, part := range expected {
+		if !strings.Contains(body, part) {
+			t.Errorf("Expected the page to contain\n%s\ngot\n%s", part, body)
+		}
+	}
+	if strings.Contains(body, `id="L3"`) {
+		t.Error("Expected no line after the final line feed")
+	}
+	if policy := response.Header().Get("Content-Security-Policy"); policy != pagePolicy {
+		t.Errorf("Expected the content security policy %s, got %s", pagePolicy, policy)
+	}
+}
+
+func TestViewerRaw(t *testing.T) {
+	document := &client.Document{Key: "abc", Content: []byte{0x89, 'P', 'N', 'G'},
+		Envelope: &client.Envelope{Filename: "dir/image.png", MIME: "image/png", Size: 4}}
+	viewer, err := New(document, "abc", "")
+	if err != nil {
+		t.Fatalf("Error creating viewer: %s", err.Error())
+	}
+
+	tests := []struct {
+		path        string
+		status      int
+		disposition string
+	}{
+		{viewer.Path() + "raw", http.StatusOK, `attachment; filename=image.png`},
+		{viewer.Path() + "raw?inline=1", http.StatusOK, `inline; filename=image.png`},
+		{"/raw", http.StatusNotFound, ""},
+		{"/", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		response := httptest.NewRecorder()
+		viewer.ServeHTTP(response, httptest.NewRequest("GET", test.path, nil))
+
+		if response.Code != test.status {
+			t.Errorf("%s: Expected status %d, got %d", test.path, test.status, response.Code)
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		if disposition := response.Header().Get("Content-Disposition"); disposition != test.disposition {
+			t.Errorf("%s: Expected disposition %s, got %s", test.path, test.disposition, disposition)
+		}
+		if response.Body.String() != string(document.Content) {
+			t.Errorf("%s: Expected the decoded content, got %q", test.path, response.Body.String())
+		}
+	}
+
+	response := httptest.NewRecorder()
+	viewer.ServeHTTP(response, httptest.NewRequest("GET", viewer.Path(), nil))
+	if !strings.Contains(response.Body.String(), `<img src="raw?inline=1" alt="dir/image.png">`) {
+		t.Errorf("Expected the page to show the image, got\n%s", response.Body.String())
+	}
+}
+
+func TestServe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err.Error())
+	}
+
+	viewer, _ := New(&client.Document{Key: "abc", Content: []byte("text\n")}, "abc", "")
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- Serve(listener, viewer, time.Minute, stop)
+	}()
+
+	address := listener.Addr().String()
+	response, err := http.Get("http://" + address + viewer.Path() + "raw")
+	if err != nil {
+		t.Fatalf("Error requesting haste: %s", err.Error())
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if string(body) != "text\n" {
+		t.Errorf("Expected the haste, got %q", body)
+	}
+
+	// a website that has rebound its domain to the loopback address
+	request, _ := http.NewRequest("GET", "http://"+address+viewer.Path(), nil)
+	request.Host = "attacker.example:" + strings.Split(address, ":")[1]
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Error requesting page: %s", err.Error())
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected foreign hosts to be forbidden, got %d", response.StatusCode)
+	}
+
+	close(stop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected Serve to stop without an error, got %s", err.Error())
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected Serve to stop")
+	}
+
+	listener, _ = net.Listen("tcp", "127.0.0.1:0")
+	started := time.Now()
+	if err := Serve(listener, viewer, 10*time.Millisecond, nil); err != nil || time.Since(started) > 5*time.Second {
+		t.Errorf("Expected Serve to stop after the timeout, got %v", err)
+	}
+}